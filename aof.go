@@ -0,0 +1,381 @@
+package rdbtools
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Represents a command of an AOF file
+type AOFCommand struct {
+	DB   int      // The database selected when the command was logged
+	Args [][]byte // The command name followed by its arguments
+}
+
+// Returns a visualization of the command
+func (c AOFCommand) String() string {
+	var name string
+	if len(c.Args) > 0 {
+		name = string(c.Args[0])
+	}
+
+	return fmt.Sprintf("AOFCommand{DB: %d, Name: %s, Args: %d}", c.DB, name, len(c.Args)-1)
+}
+
+type AOFParser interface {
+	// Parse an AOF file reading data from the provided reader r.
+	// If the file starts with a RDB preamble, its data is sent like Parser does.
+	// Like Parser, the channels are closed even if parsing fails.
+	Parse(r io.Reader) error
+
+	// Parse the base and incremental files listed by the manifest of a multi part AOF (Redis >= 7.0),
+	// dir being the appendonlydir directory.
+	ParseDir(dir string) error
+}
+
+// AOFParser is the parser for AOF files
+type aofParser struct {
+	ctx ParserContext
+	db  int // The database selected by the last SELECT command
+}
+
+// Create a new AOF parser using the provided context.
+// It panics if ctx.ErrCh isn't buffered.
+func NewAOFParser(ctx ParserContext) AOFParser {
+	ctx.checkErrCh()
+	ctx.endOfFileCh = make(chan struct{})
+	return &aofParser{ctx: ctx}
+}
+
+func (a *aofParser) Parse(r io.Reader) error {
+	if err := a.parse(r); err != nil {
+		newChannelHandler(a.ctx).fail(err)
+		return err
+	}
+
+	a.ctx.closeChannels()
+
+	return nil
+}
+
+func (a *aofParser) ParseDir(dir string) error {
+	if err := a.parseDir(dir); err != nil {
+		newChannelHandler(a.ctx).fail(err)
+		return err
+	}
+
+	a.ctx.closeChannels()
+
+	return nil
+}
+
+func (a *aofParser) parseDir(dir string) error {
+	matches, err := filepath.Glob(filepath.Join(dir, "*.manifest"))
+	if err != nil {
+		return err
+	}
+	if len(matches) != 1 {
+		return ErrInvalidAOFManifest
+	}
+
+	f, err := os.Open(matches[0])
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	manifest, err := ReadAOFManifest(f)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range manifest.Files() {
+		if err := a.parseFile(filepath.Join(dir, file.Name)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (a *aofParser) parseFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return a.parse(f)
+}
+
+func (a *aofParser) parse(r io.Reader) error {
+	br := bufio.NewReader(r)
+
+	magic, err := br.Peek(5)
+	if err != nil && err != io.EOF {
+		return err
+	}
+
+	// RDB preamble (aof-use-rdb-preamble)
+	if string(magic) == "REDIS" || string(magic) == "VALKE" {
+		p := &parser{h: newChannelHandler(a.ctx), expireAtSnapshotTime: a.ctx.ExpireAtSnapshotTime, keyDBMemberExpiries: a.ctx.KeyDBMemberExpiries}
+		if err := p.parse(br); err != nil {
+			return err
+		}
+		a.db = p.db
+	}
+
+	for {
+		args, err := readAOFCommand(br)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if args == nil {
+			continue
+		}
+
+		if strings.EqualFold(string(args[0]), "SELECT") && len(args) == 2 {
+			db, err := strconv.Atoi(string(args[1]))
+			if err != nil {
+				return ErrInvalidAOFCommand
+			}
+			a.db = db
+		}
+
+		if a.ctx.AOFCommandCh != nil {
+			a.ctx.AOFCommandCh <- AOFCommand{DB: a.db, Args: args}
+		}
+	}
+}
+
+// Read a command serialized as a RESP array of bulk strings.
+// Returns nil arguments for an annotation line, such as the timestamps of Redis >= 7.0,
+// and io.EOF if there is no more command.
+func readAOFCommand(r *bufio.Reader) ([][]byte, error) {
+	line, err := readAOFLine(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(line) > 0 && line[0] == '#' {
+		return nil, nil
+	}
+
+	n, err := parseAOFLength(line, '*')
+	if err != nil {
+		return nil, err
+	}
+	if n < 1 {
+		return nil, ErrInvalidAOFCommand
+	}
+
+	args := make([][]byte, n)
+	for i := range args {
+		line, err := readAOFLine(r)
+		if err == io.EOF {
+			return nil, io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		l, err := parseAOFLength(line, '$')
+		if err != nil {
+			return nil, err
+		}
+
+		data := make([]byte, l+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			if err == io.EOF {
+				return nil, io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		if !bytes.HasSuffix(data, []byte("\r\n")) {
+			return nil, ErrInvalidAOFCommand
+		}
+
+		args[i] = data[:l]
+	}
+
+	return args, nil
+}
+
+// Read a line terminated by \r\n, without its terminator
+func readAOFLine(r *bufio.Reader) ([]byte, error) {
+	line, err := r.ReadBytes('\n')
+	if err == io.EOF && len(line) > 0 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, ErrInvalidAOFCommand
+	}
+
+	return line[:len(line)-2], nil
+}
+
+func parseAOFLength(line []byte, prefix byte) (int, error) {
+	if len(line) < 2 || line[0] != prefix {
+		return 0, ErrInvalidAOFCommand
+	}
+
+	n, err := strconv.Atoi(string(line[1:]))
+	if err != nil || n < 0 {
+		return 0, ErrInvalidAOFCommand
+	}
+
+	return n, nil
+}
+
+// The type of a file listed in an AOF manifest
+type AOFFileType byte
+
+const (
+	AOFFileTypeBase    AOFFileType = 'b' // The base file, either an AOF or a RDB file
+	AOFFileTypeIncr    AOFFileType = 'i' // An incremental AOF file, applied after the base file
+	AOFFileTypeHistory AOFFileType = 'h' // A file waiting to be deleted after an AOF rewrite
+)
+
+// Represents a file listed in an AOF manifest
+type AOFManifestFile struct {
+	Name string
+	Seq  int64
+	Type AOFFileType
+}
+
+// Represents the manifest of a multi part AOF (Redis >= 7.0)
+type AOFManifest struct {
+	Base *AOFManifestFile
+	Incr []AOFManifestFile // Sorted by sequence number
+}
+
+// Returns the files to load in order: the base file if any and then the incremental files
+func (m AOFManifest) Files() []AOFManifestFile {
+	files := make([]AOFManifestFile, 0, len(m.Incr)+1)
+	if m.Base != nil {
+		files = append(files, *m.Base)
+	}
+
+	return append(files, m.Incr...)
+}
+
+// Read an AOF manifest, where each line describes a file:
+//
+//	file appendonly.aof.1.base.rdb seq 1 type b
+//
+// History files are ignored.
+func ReadAOFManifest(r io.Reader) (AOFManifest, error) {
+	var manifest AOFManifest
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		fields, err := splitAOFManifestLine(line)
+		if err != nil {
+			return manifest, err
+		}
+		if len(fields)%2 != 0 {
+			return manifest, ErrInvalidAOFManifest
+		}
+
+		var file AOFManifestFile
+		for i := 0; i < len(fields); i += 2 {
+			switch fields[i] {
+			case "file":
+				file.Name = fields[i+1]
+			case "seq":
+				if file.Seq, err = strconv.ParseInt(fields[i+1], 10, 64); err != nil {
+					return manifest, ErrInvalidAOFManifest
+				}
+			case "type":
+				if len(fields[i+1]) != 1 {
+					return manifest, ErrInvalidAOFManifest
+				}
+				file.Type = AOFFileType(fields[i+1][0])
+			}
+		}
+
+		if file.Name == "" {
+			return manifest, ErrInvalidAOFManifest
+		}
+
+		switch file.Type {
+		case AOFFileTypeBase:
+			if manifest.Base != nil {
+				return manifest, ErrInvalidAOFManifest
+			}
+			manifest.Base = &file
+		case AOFFileTypeIncr:
+			manifest.Incr = append(manifest.Incr, file)
+		case AOFFileTypeHistory:
+		default:
+			return manifest, ErrInvalidAOFManifest
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return manifest, err
+	}
+
+	sort.SliceStable(manifest.Incr, func(i, j int) bool { return manifest.Incr[i].Seq < manifest.Incr[j].Seq })
+
+	return manifest, nil
+}
+
+// Split a manifest line on spaces, file names with spaces being quoted
+func splitAOFManifestLine(line string) ([]string, error) {
+	var fields []string
+
+	for i := 0; i < len(line); {
+		if line[i] == ' ' {
+			i++
+			continue
+		}
+
+		if line[i] != '"' {
+			j := strings.IndexByte(line[i:], ' ')
+			if j < 0 {
+				j = len(line) - i
+			}
+			fields = append(fields, line[i:i+j])
+			i += j
+			continue
+		}
+
+		var field strings.Builder
+		i++
+		for {
+			if i >= len(line) {
+				return nil, ErrInvalidAOFManifest
+			}
+			if line[i] == '"' {
+				i++
+				break
+			}
+			if line[i] == '\\' && i+1 < len(line) {
+				i++
+			}
+			field.WriteByte(line[i])
+			i++
+		}
+		fields = append(fields, field.String())
+	}
+
+	return fields, nil
+}
@@ -0,0 +1,305 @@
+package rdbtools
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestAOFCommandString(t *testing.T) {
+	c := AOFCommand{DB: 1, Args: [][]byte{[]byte("SET"), []byte("foo"), []byte("bar")}}
+	equals(t, "AOFCommand{DB: 1, Name: SET, Args: 2}", c.String())
+}
+
+// Write a command as a RESP array of bulk strings
+func writeAOFCommand(w io.Writer, args ...string) {
+	io.WriteString(w, "*"+strconv.Itoa(len(args))+"\r\n")
+	for _, arg := range args {
+		io.WriteString(w, "$"+strconv.Itoa(len(arg))+"\r\n"+arg+"\r\n")
+	}
+}
+
+// Write a RDB file with a single string key
+func writeTestRDB(w io.Writer, key, value string) {
+	var buffer bytes.Buffer
+	buffer.WriteString("REDIS0011")
+	buffer.WriteByte(0xFE) // next database byte
+	buffer.WriteByte(0)    // database number
+	buffer.WriteByte(0)    // string
+	writeString(&buffer, key)
+	writeString(&buffer, value)
+	buffer.WriteByte(0xFF) // end of file
+	writeChecksum(&buffer)
+
+	w.Write(buffer.Bytes())
+}
+
+// Run parse and collect the commands and string objects it sends
+func collectAOF(t *testing.T, ctx ParserContext, parse func() error) ([]AOFCommand, []StringObject) {
+	go func() {
+		if err := parse(); err != nil {
+			t.Errorf("Error while parsing; err=%s", err)
+		}
+	}()
+
+	var commands []AOFCommand
+	var stringObjects []StringObject
+	for {
+		select {
+		case v, ok := <-ctx.AOFCommandCh:
+			if !ok {
+				ctx.AOFCommandCh = nil
+				break
+			}
+			commands = append(commands, v)
+		case v, ok := <-ctx.StringObjectCh:
+			if !ok {
+				ctx.StringObjectCh = nil
+				break
+			}
+			stringObjects = append(stringObjects, v)
+		}
+
+		if ctx.Invalid() {
+			break
+		}
+	}
+
+	return commands, stringObjects
+}
+
+func commandNames(commands []AOFCommand) []string {
+	names := make([]string, len(commands))
+	for i, c := range commands {
+		names[i] = string(c.Args[0])
+	}
+	return names
+}
+
+func TestAOFParse(t *testing.T) {
+	var buffer bytes.Buffer
+	writeAOFCommand(&buffer, "SELECT", "0")
+	writeAOFCommand(&buffer, "SET", "foo", "bar")
+	buffer.WriteString("#TS:1700000000\r\n")
+	writeAOFCommand(&buffer, "SELECT", "2")
+	writeAOFCommand(&buffer, "RPUSH", "list", "a\r\nb")
+
+	ctx := ParserContext{AOFCommandCh: make(chan AOFCommand)}
+	p := NewAOFParser(ctx)
+
+	commands, _ := collectAOF(t, ctx, func() error { return p.Parse(&buffer) })
+
+	equals(t, []string{"SELECT", "SET", "SELECT", "RPUSH"}, commandNames(commands))
+	equals(t, 0, commands[1].DB)
+	equals(t, [][]byte{[]byte("SET"), []byte("foo"), []byte("bar")}, commands[1].Args)
+	equals(t, 2, commands[3].DB)
+	equals(t, []byte("a\r\nb"), commands[3].Args[2])
+}
+
+func TestAOFParseRDBPreamble(t *testing.T) {
+	var buffer bytes.Buffer
+	writeTestRDB(&buffer, "foo", "bar")
+	writeAOFCommand(&buffer, "SET", "baz", "qux")
+
+	ctx := ParserContext{
+		StringObjectCh: make(chan StringObject),
+		AOFCommandCh:   make(chan AOFCommand),
+	}
+	p := NewAOFParser(ctx)
+
+	commands, stringObjects := collectAOF(t, ctx, func() error { return p.Parse(&buffer) })
+
+	equals(t, 1, len(stringObjects))
+	equals(t, "foo", DataToString(stringObjects[0].Key))
+	equals(t, "bar", DataToString(stringObjects[0].Value))
+	equals(t, []string{"SET"}, commandNames(commands))
+}
+
+func TestAOFParseEmpty(t *testing.T) {
+	var buffer bytes.Buffer
+
+	ctx := ParserContext{AOFCommandCh: make(chan AOFCommand)}
+	p := NewAOFParser(ctx)
+
+	commands, _ := collectAOF(t, ctx, func() error { return p.Parse(&buffer) })
+	equals(t, 0, len(commands))
+}
+
+func TestAOFParseInvalidSelect(t *testing.T) {
+	var buffer bytes.Buffer
+	writeAOFCommand(&buffer, "SELECT", "foo")
+
+	p := NewAOFParser(ParserContext{})
+	err := p.Parse(&buffer)
+	equals(t, ErrInvalidAOFCommand, err)
+}
+
+func TestReadAOFCommand(t *testing.T) {
+	var buffer bytes.Buffer
+	writeAOFCommand(&buffer, "SET", "foo", "")
+
+	args, err := readAOFCommand(bufio.NewReader(&buffer))
+	ok(t, err)
+	equals(t, [][]byte{[]byte("SET"), []byte("foo"), []byte("")}, args)
+
+	// Annotation
+	args, err = readAOFCommand(bufio.NewReader(bytes.NewReader([]byte("#TS:1700000000\r\n"))))
+	ok(t, err)
+	equals(t, [][]byte(nil), args)
+
+	// No data
+	_, err = readAOFCommand(bufio.NewReader(bytes.NewReader(nil)))
+	equals(t, io.EOF, err)
+
+	// Truncated command
+	buffer.Reset()
+	writeAOFCommand(&buffer, "SET", "foo", "bar")
+	data := buffer.Bytes()
+
+	for _, l := range []int{2, 4, 10, len(data) - 1} {
+		_, err = readAOFCommand(bufio.NewReader(bytes.NewReader(data[:l])))
+		equals(t, io.ErrUnexpectedEOF, err)
+	}
+
+	// Invalid commands
+	for _, s := range []string{
+		"SET foo bar\r\n",
+		"*0\r\n",
+		"*a\r\n",
+		"*1\n",
+		"*1\r\n+SET\r\n",
+		"*1\r\n$-1\r\n",
+		"*1\r\n$3\r\nSETX\n",
+	} {
+		_, err = readAOFCommand(bufio.NewReader(bytes.NewReader([]byte(s))))
+		equals(t, ErrInvalidAOFCommand, err)
+	}
+}
+
+const testAOFManifest = `file appendonly.aof.2.incr.aof seq 2 type i
+file appendonly.aof.1.base.rdb seq 1 type b
+file appendonly.aof.1.incr.aof seq 1 type i
+file "appendonly history.aof" seq 1 type h
+`
+
+func TestReadAOFManifest(t *testing.T) {
+	m, err := ReadAOFManifest(bytes.NewReader([]byte(testAOFManifest)))
+	ok(t, err)
+
+	equals(t, &AOFManifestFile{Name: "appendonly.aof.1.base.rdb", Seq: 1, Type: AOFFileTypeBase}, m.Base)
+	equals(t, []AOFManifestFile{
+		{Name: "appendonly.aof.1.base.rdb", Seq: 1, Type: AOFFileTypeBase},
+		{Name: "appendonly.aof.1.incr.aof", Seq: 1, Type: AOFFileTypeIncr},
+		{Name: "appendonly.aof.2.incr.aof", Seq: 2, Type: AOFFileTypeIncr},
+	}, m.Files())
+}
+
+func TestReadAOFManifestQuotedName(t *testing.T) {
+	m, err := ReadAOFManifest(bytes.NewReader([]byte(`file "append \"only\".aof" seq 3 type i`)))
+	ok(t, err)
+	equals(t, []AOFManifestFile{{Name: `append "only".aof`, Seq: 3, Type: AOFFileTypeIncr}}, m.Incr)
+}
+
+func TestReadAOFManifestInvalid(t *testing.T) {
+	for _, s := range []string{
+		"file a.aof seq 1",
+		"file a.aof seq a type i",
+		"file a.aof seq 1 type x",
+		"file a.aof seq 1 type ii",
+		"seq 1 type i",
+		`file "a.aof seq 1 type i`,
+		"file a.rdb seq 1 type b\nfile b.rdb seq 2 type b",
+	} {
+		_, err := ReadAOFManifest(bytes.NewReader([]byte(s)))
+		equals(t, ErrInvalidAOFManifest, err)
+	}
+}
+
+func TestAOFParseDir(t *testing.T) {
+	dir := t.TempDir()
+
+	writeFile := func(name string, data []byte) {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var buffer bytes.Buffer
+	writeTestRDB(&buffer, "foo", "bar")
+	writeFile("appendonly.aof.1.base.rdb", buffer.Bytes())
+
+	buffer.Reset()
+	writeAOFCommand(&buffer, "SELECT", "0")
+	writeAOFCommand(&buffer, "SET", "a", "1")
+	writeFile("appendonly.aof.1.incr.aof", buffer.Bytes())
+
+	buffer.Reset()
+	writeAOFCommand(&buffer, "DEL", "a")
+	writeFile("appendonly.aof.2.incr.aof", buffer.Bytes())
+
+	writeFile("appendonly.aof.manifest", []byte(testAOFManifest))
+
+	ctx := ParserContext{
+		StringObjectCh: make(chan StringObject),
+		AOFCommandCh:   make(chan AOFCommand),
+	}
+	p := NewAOFParser(ctx)
+
+	commands, stringObjects := collectAOF(t, ctx, func() error { return p.ParseDir(dir) })
+
+	equals(t, 1, len(stringObjects))
+	equals(t, "foo", DataToString(stringObjects[0].Key))
+	equals(t, []string{"SELECT", "SET", "DEL"}, commandNames(commands))
+}
+
+func TestAOFParseDirNoManifest(t *testing.T) {
+	p := NewAOFParser(ParserContext{})
+	err := p.ParseDir(t.TempDir())
+	equals(t, ErrInvalidAOFManifest, err)
+}
+
+func TestAOFParseRDBPreambleKeyDBMemberExpiries(t *testing.T) {
+	var buffer bytes.Buffer
+	writeKeyDBMemberExpiryRDB(&buffer)
+
+	ctx := ParserContext{
+		SetDataCh:           make(chan interface{}),
+		MemberExpiryCh:      make(chan MemberExpiry),
+		KeyDBMemberExpiries: true,
+	}
+	p := NewAOFParser(ctx)
+
+	go func() {
+		if err := p.Parse(&buffer); err != nil {
+			t.Errorf("Error while parsing; err=%s", err)
+		}
+	}()
+
+	var events []string
+	for !ctx.Invalid() {
+		select {
+		case v, ok := <-ctx.SetDataCh:
+			if !ok {
+				ctx.SetDataCh = nil
+				break
+			}
+			events = append(events, DataToString(v))
+		case v, ok := <-ctx.MemberExpiryCh:
+			if !ok {
+				ctx.MemberExpiryCh = nil
+				break
+			}
+			events = append(events, v.String())
+		}
+	}
+
+	equals(t, []string{
+		"a",
+		"MemberExpiry{Key: set, Member: a, ExpiryTime: 2023-11-14 22:13:20.5 +0000 UTC}",
+		"b",
+	}, events)
+}
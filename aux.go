@@ -0,0 +1,57 @@
+package rdbtools
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Represents an auxiliary field of a RDB file (redis-ver, redis-bits, ctime, used-mem, etc).
+// Auxiliary fields exist since RDB version 7.
+type AuxField struct {
+	Key   interface{}
+	Value interface{}
+}
+
+// Returns a visualization of the auxiliary field
+func (f AuxField) String() string {
+	return fmt.Sprintf("AuxField{Key: %s, Value: %s}", DataToString(f.Key), DataToString(f.Value))
+}
+
+func (p *parser) readAuxField(r io.Reader) error {
+	key, err := p.readString(r)
+	if err != nil {
+		return err
+	}
+
+	value, err := p.readString(r)
+	if err != nil {
+		return err
+	}
+
+	if !isKeyDBSubexpireField(DataToString(key)) {
+		if err := p.flushPending(); err != nil {
+			return err
+		}
+	}
+
+	if err := p.h.AuxField(AuxField{Key: key, Value: value}); err != nil {
+		return err
+	}
+
+	switch DataToString(key) {
+	case "ctime":
+		// The creation time of the RDB file, in seconds
+		if ctime, err := strconv.ParseInt(DataToString(value), 10, 64); err == nil {
+			p.ctime = time.Unix(ctime, 0).UTC()
+		}
+	case "lua":
+		// Redis < 7.0 saves each Lua script in a "lua" auxiliary field
+		return p.sendLuaScript(value)
+	case "mvcc-tstamp", "keydb-subexpire-key", "keydb-subexpire-when":
+		return p.readKeyDBAuxField(DataToString(key), value)
+	}
+
+	return nil
+}
@@ -0,0 +1,80 @@
+package rdbtools
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestAuxFieldString(t *testing.T) {
+	f := AuxField{Key: "redis-ver", Value: "7.2.4"}
+	equals(t, "AuxField{Key: redis-ver, Value: 7.2.4}", f.String())
+}
+
+func TestReadAuxField(t *testing.T) {
+	var buffer bytes.Buffer
+
+	br := bufio.NewWriter(&buffer)
+	br.WriteByte(9)
+	br.WriteString("redis-ver")
+	br.WriteByte(5)
+	br.WriteString("7.2.4")
+	br.Flush()
+
+	ctx := ParserContext{AuxFieldCh: make(chan AuxField)}
+	p := &parser{h: newChannelHandler(ctx)}
+
+	go func() {
+		f := <-ctx.AuxFieldCh
+		equals(t, "redis-ver", DataToString(f.Key))
+		equals(t, "7.2.4", DataToString(f.Value))
+	}()
+
+	err := p.readAuxField(bufio.NewReader(&buffer))
+	ok(t, err)
+}
+
+func TestReadAuxFieldIntegerValue(t *testing.T) {
+	var buffer bytes.Buffer
+
+	br := bufio.NewWriter(&buffer)
+	br.WriteByte(10)
+	br.WriteString("redis-bits")
+	br.WriteByte(0xC0) // INT8 encoding
+	br.WriteByte(64)
+	br.Flush()
+
+	ctx := ParserContext{AuxFieldCh: make(chan AuxField)}
+	p := &parser{h: newChannelHandler(ctx)}
+
+	go func() {
+		f := <-ctx.AuxFieldCh
+		equals(t, "redis-bits", DataToString(f.Key))
+		equals(t, int8(64), f.Value)
+	}()
+
+	err := p.readAuxField(bufio.NewReader(&buffer))
+	ok(t, err)
+}
+
+func TestReadAuxFieldNoData(t *testing.T) {
+	var buffer bytes.Buffer
+
+	p := &parser{}
+	err := p.readAuxField(bufio.NewReader(&buffer))
+	equals(t, io.EOF, err)
+}
+
+func TestReadAuxFieldNoValue(t *testing.T) {
+	var buffer bytes.Buffer
+
+	br := bufio.NewWriter(&buffer)
+	br.WriteByte(5)
+	br.WriteString("ctime")
+	br.Flush()
+
+	p := &parser{}
+	err := p.readAuxField(bufio.NewReader(&buffer))
+	equals(t, io.EOF, err)
+}
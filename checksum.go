@@ -35,6 +35,13 @@ func (r *checksumReader) updateChecksum(p []byte) {
 	}
 }
 
+// Returns the CRC64 checksum of data, as used by RDB files and DUMP payloads
+func crc64(data []byte) uint64 {
+	r := newChecksumReader(nil)
+	r.updateChecksum(data)
+	return r.checksum
+}
+
 func makeCRCTable() []uint64 {
 	table := make([]uint64, 256)
 	var i uint64
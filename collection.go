@@ -0,0 +1,28 @@
+package rdbtools
+
+import "fmt"
+
+// Represents an element or the end of a list, set, hash, sorted set or stream,
+// sent with the key of the collection.
+//
+// Value is:
+//
+//   - the element for a list or a set
+//   - a HashEntry for a hash
+//   - a SortedSetEntry for a sorted set
+//   - a StreamEntry or a StreamConsumerGroup for a stream
+type CollectionEvent struct {
+	Key   KeyObject // The key of the collection, KeyObject.DB being its database
+	Type  string    // list, set, hash, zset or stream
+	End   bool      // true once all the elements of the collection have been sent, Value being nil
+	Value interface{}
+}
+
+// Returns a visualization of the collection event
+func (e CollectionEvent) String() string {
+	if e.End {
+		return fmt.Sprintf("CollectionEvent{DB: %d, Key: %s, Type: %s, End: true}", e.Key.DB, DataToString(e.Key), e.Type)
+	}
+
+	return fmt.Sprintf("CollectionEvent{DB: %d, Key: %s, Type: %s, Value: %s}", e.Key.DB, DataToString(e.Key), e.Type, DataToString(e.Value))
+}
@@ -0,0 +1,118 @@
+package rdbtools
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCollectionEventString(t *testing.T) {
+	key := KeyObject{Key: []byte("list"), DB: 2}
+
+	e := CollectionEvent{Key: key, Type: "list", Value: []byte("a")}
+	equals(t, "CollectionEvent{DB: 2, Key: list, Type: list, Value: a}", e.String())
+
+	e = CollectionEvent{Key: key, Type: "list", End: true}
+	equals(t, "CollectionEvent{DB: 2, Key: list, Type: list, End: true}", e.String())
+}
+
+// Parse r and collect the collection events
+func collectCollectionEvents(t *testing.T, p Parser, ctx ParserContext, r *bytes.Reader) []CollectionEvent {
+	go mustParse(t, p, ctx, r)
+
+	var events []CollectionEvent
+	for e := range ctx.CollectionEventCh {
+		events = append(events, e)
+	}
+
+	return events
+}
+
+func TestParseCollectionEvents(t *testing.T) {
+	var buffer bytes.Buffer
+
+	br := bufio.NewWriter(&buffer)
+	br.WriteString("REDIS0011")
+	br.WriteByte(0xFE) // next database byte
+	br.WriteByte(0)    // database number
+	br.WriteByte(1)    // list
+	writeString(br, "l")
+	writeLen(br, 2)
+	writeString(br, "a")
+	writeString(br, "b")
+	br.WriteByte(0) // string
+	writeString(br, "s")
+	writeString(br, "foo")
+	br.WriteByte(0xFE) // next database byte
+	br.WriteByte(1)    // database number
+	br.WriteByte(20)   // set in listpack encoding
+	writeString(br, "l")
+	writeString(br, string(newListpack("x")))
+	br.WriteByte(4) // hash
+	writeString(br, "h")
+	writeLen(br, 1)
+	writeString(br, "f")
+	writeString(br, "v")
+	br.WriteByte(5) // sorted set with binary scores
+	writeString(br, "z")
+	writeLen(br, 1)
+	writeString(br, "m")
+	br.Write([]byte{0, 0, 0, 0, 0, 0, 0xf8, 0x3f}) // 1.5
+	br.WriteByte(0xFF)                             // end of file
+	br.Flush()
+	writeChecksum(&buffer)
+
+	// Only the collection events are read, the other channels being unused
+	ctx := ParserContext{CollectionEventCh: make(chan CollectionEvent)}
+	events := collectCollectionEvents(t, NewParser(ctx), ctx, bytes.NewReader(buffer.Bytes()))
+
+	var got []string
+	for _, e := range events {
+		got = append(got, e.String())
+	}
+
+	equals(t, []string{
+		"CollectionEvent{DB: 0, Key: l, Type: list, Value: a}",
+		"CollectionEvent{DB: 0, Key: l, Type: list, Value: b}",
+		"CollectionEvent{DB: 0, Key: l, Type: list, End: true}",
+		"CollectionEvent{DB: 1, Key: l, Type: set, Value: x}",
+		"CollectionEvent{DB: 1, Key: l, Type: set, End: true}",
+		"CollectionEvent{DB: 1, Key: h, Type: hash, Value: HashEntry{Key: f, Value: v}}",
+		"CollectionEvent{DB: 1, Key: h, Type: hash, End: true}",
+		"CollectionEvent{DB: 1, Key: z, Type: zset, Value: SortedSetEntry{Value: m, Score: 1.5000}}",
+		"CollectionEvent{DB: 1, Key: z, Type: zset, End: true}",
+	}, got)
+}
+
+// The elements of each collection of the dumps must be followed by its end event
+func TestParseCollectionEventsDumps(t *testing.T) {
+	paths, err := filepath.Glob("dumps/*.rdb")
+	ok(t, err)
+
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		ok(t, err)
+
+		ctx := ParserContext{CollectionEventCh: make(chan CollectionEvent)}
+		events := collectCollectionEvents(t, NewParser(ctx), ctx, bytes.NewReader(data))
+
+		var elements []CollectionEvent
+		for _, e := range events {
+			if !e.End {
+				elements = append(elements, e)
+				continue
+			}
+
+			for _, el := range elements {
+				equals(t, e.Key.DB, el.Key.DB)
+				equals(t, DataToString(e.Key), DataToString(el.Key))
+				equals(t, e.Type, el.Type)
+			}
+			elements = nil
+		}
+
+		equals(t, 0, len(elements))
+	}
+}
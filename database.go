@@ -0,0 +1,54 @@
+package rdbtools
+
+import (
+	"fmt"
+	"io"
+)
+
+// Represents the metadata of a database, which is its number and the size hints stored in the RDB file.
+// The hints come from the RESIZEDB opcode written since RDB version 7; they are -1 if the file doesn't have them.
+type DatabaseMetadata struct {
+	Number     int
+	Len        int64 // Number of keys in the database
+	ExpiresLen int64 // Number of keys with an expiry time in the database
+}
+
+// Returns a visualization of the database metadata
+func (m DatabaseMetadata) String() string {
+	return fmt.Sprintf("DatabaseMetadata{Number: %d, Len: %d, ExpiresLen: %d}", m.Number, m.Len, m.ExpiresLen)
+}
+
+// Send the metadata of the current database. b is the first opcode read after the SELECTDB opcode;
+// if it's a RESIZEDB opcode the size hints are read from r.
+func (p *parser) readDatabaseMetadata(b byte, r io.Reader) error {
+	md := DatabaseMetadata{Number: p.db, Len: -1, ExpiresLen: -1}
+
+	if b == 0xFB {
+		l, e, err := p.readLen(r)
+		if err != nil {
+			return err
+		}
+		if e {
+			return ErrUnexpectedEncodedLength
+		}
+
+		el, e, err := p.readLen(r)
+		if err != nil {
+			return err
+		}
+		if e {
+			return ErrUnexpectedEncodedLength
+		}
+
+		md.Len = int64(l)
+		md.ExpiresLen = int64(el)
+	}
+
+	p.dbMetadataSent = true
+
+	if err := p.h.DatabaseMetadata(md); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,82 @@
+package rdbtools
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestDatabaseMetadataString(t *testing.T) {
+	md := DatabaseMetadata{Number: 1, Len: 10, ExpiresLen: 2}
+	equals(t, "DatabaseMetadata{Number: 1, Len: 10, ExpiresLen: 2}", md.String())
+}
+
+func TestReadDatabaseMetadata(t *testing.T) {
+	var buffer bytes.Buffer
+
+	br := bufio.NewWriter(&buffer)
+	br.Write([]byte{0x41, 0x00}) // main dict size
+	br.WriteByte(3)              // expires dict size
+	br.Flush()
+
+	ctx := ParserContext{DatabaseMetadataCh: make(chan DatabaseMetadata)}
+	p := &parser{h: newChannelHandler(ctx), db: 2}
+
+	go func() {
+		md := <-ctx.DatabaseMetadataCh
+		equals(t, DatabaseMetadata{Number: 2, Len: 256, ExpiresLen: 3}, md)
+	}()
+
+	err := p.readDatabaseMetadata(0xFB, bufio.NewReader(&buffer))
+	ok(t, err)
+	equals(t, true, p.dbMetadataSent)
+}
+
+func TestReadDatabaseMetadataNoResizeDB(t *testing.T) {
+	var buffer bytes.Buffer
+
+	ctx := ParserContext{DatabaseMetadataCh: make(chan DatabaseMetadata)}
+	p := &parser{h: newChannelHandler(ctx), db: 2}
+
+	go func() {
+		md := <-ctx.DatabaseMetadataCh
+		equals(t, DatabaseMetadata{Number: 2, Len: -1, ExpiresLen: -1}, md)
+	}()
+
+	err := p.readDatabaseMetadata(0, bufio.NewReader(&buffer))
+	ok(t, err)
+	equals(t, true, p.dbMetadataSent)
+}
+
+func TestReadDatabaseMetadataNoData(t *testing.T) {
+	var buffer bytes.Buffer
+
+	p := &parser{}
+	err := p.readDatabaseMetadata(0xFB, bufio.NewReader(&buffer))
+	equals(t, io.EOF, err)
+}
+
+func TestReadDatabaseMetadataNoExpiresSize(t *testing.T) {
+	var buffer bytes.Buffer
+
+	br := bufio.NewWriter(&buffer)
+	br.WriteByte(1)
+	br.Flush()
+
+	p := &parser{}
+	err := p.readDatabaseMetadata(0xFB, bufio.NewReader(&buffer))
+	equals(t, io.EOF, err)
+}
+
+func TestReadDatabaseMetadataEncodedLen(t *testing.T) {
+	var buffer bytes.Buffer
+
+	br := bufio.NewWriter(&buffer)
+	br.WriteByte(0xC0)
+	br.Flush()
+
+	p := &parser{}
+	err := p.readDatabaseMetadata(0xFB, bufio.NewReader(&buffer))
+	equals(t, ErrUnexpectedEncodedLength, err)
+}
@@ -50,6 +50,61 @@
 // The parser only has one method Parse(ParserContext) which takes a context. After a call to Parse,
 // the parser can't be reused. We plan to change that though.
 //
+// The channels are closed once parsing ends, even if it fails. Provide a buffered ErrCh
+// to receive the error, which is sent after the other channels are closed. ParseContext
+// stops parsing once its context is done, which doesn't leave the parser blocked if you
+// stop reading the channels.
+//
+// The elements sent on ListDataCh, SetDataCh, HashDataCh and SortedSetEntriesCh don't
+// carry their key. CollectionEventCh sends them with the key of their collection instead,
+// followed by an event marking the end of the collection.
+//
+// Using a handler
+//
+// Instead of channels, the parser can call the methods of a Handler synchronously,
+// without any goroutine. Embed NopHandler to only implement the methods you care about.
+//
+//  type listHandler struct {
+//  	rdbtools.NopHandler
+//  }
+//
+//  func (h *listHandler) ListElement(e interface{}) error {
+//  	str := rdbtools.DataToString(e)
+//  	// do something with the string
+//  	return nil
+//  }
+//
+//  p := rdbtools.NewHandlerParser(&listHandler{})
+//  if err := p.Parse(f); err != nil {
+//  	log.Fatalln(err)
+//  }
+//
+// The channels of a ParserContext are implemented on top of a handler.
+//
+// NewHandlerParser and NewReader are configured with options such as WithExpireAtSnapshotTime,
+// a ParserContext with its fields.
+//
+// Reading keys one by one
+//
+// A Reader returns the keys of a RDB file in a loop. The values are only decoded
+// when asked for, the others are skipped.
+//
+//  r := rdbtools.NewReader(f)
+//  for {
+//  	rec, err := r.Next()
+//  	if err == io.EOF {
+//  		break
+//  	}
+//  	if err != nil {
+//  		log.Fatalln(err)
+//  	}
+//
+//  	if rec.Type == "hash" {
+//  		v, err := rec.Value()
+//  		// do something with the hash
+//  	}
+//  }
+//
 // Why interfaces everywhere
 //
 // interface{} is used everywhere in rdbtools. The reason is simple: in RDB files, keys and values
@@ -0,0 +1,276 @@
+package rdbtools
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+)
+
+// The RDB version written by EncodeDumpPayload, which can be restored by Redis >= 5.0
+const dumpPayloadRdbVersion = 9
+
+// The RDB version written by EncodeDumpPayload for the hashes with field expiration times,
+// which can be restored by Redis >= 7.4
+const dumpPayloadHashMetadataRdbVersion = 12
+
+// The values returned by DecodeDumpPayload, and accepted by EncodeDumpPayload except DumpStream.
+// Module values are returned as a ModuleObject.
+type (
+	DumpString    []byte
+	DumpList      [][]byte
+	DumpSet       [][]byte
+	DumpSortedSet []SortedSetEntry
+	DumpHash      []HashEntry
+)
+
+// Represents a stream decoded from a DUMP payload
+type DumpStream struct {
+	Metadata StreamMetadata
+	Entries  []StreamEntry
+	Groups   []StreamConsumerGroup
+}
+
+// Decode a payload returned by the DUMP command, which is a value in RDB encoding
+// followed by the RDB version (2 bytes) and a CRC64 checksum (8 bytes).
+func DecodeDumpPayload(payload []byte) (interface{}, error) {
+	if len(payload) < 11 {
+		return nil, ErrInvalidDumpPayload
+	}
+
+	footer := payload[len(payload)-10:]
+	header := Header{Flavour: FlavourRedis, Version: int(binary.LittleEndian.Uint16(footer[0:2]))}
+	if header.Version >= ValkeyMinRdbVersion && header.Version <= ValkeyRdbVersion {
+		header.Flavour = FlavourValkey
+	} else if header.Version < 1 || header.Version > RedisRdbVersion {
+		return nil, ErrInvalidRDBVersionNumber
+	}
+
+	// A zero checksum means the payload was produced with rdbchecksum disabled
+	sum := binary.LittleEndian.Uint64(footer[2:])
+	if sum != 0 && sum != crc64(payload[:len(payload)-8]) {
+		return nil, ErrInvalidChecksum
+	}
+
+	br := bytes.NewReader(payload[1 : len(payload)-10])
+	value, err := decodeDumpValue(header, payload[0], bufio.NewReader(br))
+	if err != nil {
+		return nil, err
+	}
+
+	return value, nil
+}
+
+// Read a value with the parser and collect what it sends
+func decodeDumpValue(header Header, typ byte, r *bufio.Reader) (interface{}, error) {
+	h := &dumpHandler{}
+	p := &parser{h: h, header: header}
+
+	if err := p.readValue(KeyObject{}, typ, r); err != nil {
+		// A length larger than the rest of the payload
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, ErrInvalidDumpPayload
+		}
+		return nil, err
+	}
+
+	// The value must end right before the footer
+	if _, err := r.ReadByte(); err != io.EOF {
+		return nil, ErrInvalidDumpPayload
+	}
+
+	return h.result(typ), nil
+}
+
+// dumpHandler collects the value of a DUMP payload
+type dumpHandler struct {
+	NopHandler
+
+	value  interface{}
+	list   [][]byte
+	hash   DumpHash
+	zset   DumpSortedSet
+	stream DumpStream
+}
+
+// Returns the value collected for a value of type typ
+func (h *dumpHandler) result(typ byte) interface{} {
+	switch typ {
+	case 1, 10, 14, 18:
+		return DumpList(h.list)
+	case 2, 11, 20:
+		return DumpSet(h.list)
+	case 3, 5, 12, 17:
+		return h.zset
+	case 4, 9, 13, 16, 22, 23, 24, 25:
+		return h.hash
+	case 15, 19, 21:
+		return h.stream
+	}
+
+	return h.value
+}
+
+func (h *dumpHandler) String(o StringObject) error {
+	h.value = DumpString(DataToString(o.Value))
+	return nil
+}
+
+func (h *dumpHandler) ListElement(e interface{}) error {
+	h.list = append(h.list, []byte(DataToString(e)))
+	return nil
+}
+
+func (h *dumpHandler) SetMember(e interface{}) error {
+	h.list = append(h.list, []byte(DataToString(e)))
+	return nil
+}
+
+func (h *dumpHandler) HashEntry(e HashEntry) error {
+	h.hash = append(h.hash, e)
+	return nil
+}
+
+func (h *dumpHandler) SortedSetEntry(e SortedSetEntry) error {
+	h.zset = append(h.zset, e)
+	return nil
+}
+
+func (h *dumpHandler) StartStream(md StreamMetadata) error {
+	h.stream.Metadata = md
+	return nil
+}
+
+func (h *dumpHandler) StreamEntry(e StreamEntry) error {
+	h.stream.Entries = append(h.stream.Entries, e)
+	return nil
+}
+
+func (h *dumpHandler) StreamConsumerGroup(g StreamConsumerGroup) error {
+	h.stream.Groups = append(h.stream.Groups, g)
+	return nil
+}
+
+func (h *dumpHandler) Module(o ModuleObject) error {
+	h.value = o
+	return nil
+}
+
+// Encode a value as a payload which can be restored with the RESTORE command.
+// value must be a DumpString, DumpList, DumpSet, DumpSortedSet, DumpHash or a ModuleObject
+// with its raw serialization, as returned by DecodeDumpPayload without a registered decoder.
+func EncodeDumpPayload(value interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	version := dumpPayloadRdbVersion
+
+	switch v := value.(type) {
+	case DumpString:
+		buf.WriteByte(0)
+		encodeString(&buf, v)
+	case DumpList:
+		buf.WriteByte(1)
+		encodeLen(&buf, uint64(len(v)))
+		for _, e := range v {
+			encodeString(&buf, e)
+		}
+	case DumpSet:
+		buf.WriteByte(2)
+		encodeLen(&buf, uint64(len(v)))
+		for _, e := range v {
+			encodeString(&buf, e)
+		}
+	case DumpSortedSet:
+		buf.WriteByte(5)
+		encodeLen(&buf, uint64(len(v)))
+		for _, e := range v {
+			encodeString(&buf, []byte(DataToString(e.Value)))
+			binary.Write(&buf, binary.LittleEndian, math.Float64bits(e.Score))
+		}
+	case DumpHash:
+		if v.hasFieldExpiry() {
+			encodeHashWithMetadata(&buf, v)
+			version = dumpPayloadHashMetadataRdbVersion
+			break
+		}
+
+		buf.WriteByte(4)
+		encodeLen(&buf, uint64(len(v)))
+		for _, e := range v {
+			encodeString(&buf, []byte(DataToString(e.Key)))
+			encodeString(&buf, []byte(DataToString(e.Value)))
+		}
+	case ModuleObject:
+		id, ok := encodeModuleID(v.ModuleName, v.Version)
+		if !ok || v.Raw == nil {
+			return nil, ErrUnsupportedDumpValue
+		}
+
+		buf.WriteByte(7)
+		encodeLen(&buf, id)
+		buf.Write(v.Raw)
+	default:
+		return nil, ErrUnsupportedDumpValue
+	}
+
+	binary.Write(&buf, binary.LittleEndian, uint16(version))
+	binary.Write(&buf, binary.LittleEndian, crc64(buf.Bytes()))
+
+	return buf.Bytes(), nil
+}
+
+// Whether a field of the hash has an expiry time
+func (h DumpHash) hasFieldExpiry() bool {
+	for _, e := range h {
+		if !e.ExpiryTime.IsZero() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Write a hash with field expiration times (type 24), the reverse of readHashMapWithMetadata
+func encodeHashWithMetadata(w *bytes.Buffer, h DumpHash) {
+	minExpire := int64(math.MaxInt64)
+	for _, e := range h {
+		if ms := timeToMilliseconds(e.ExpiryTime); !e.ExpiryTime.IsZero() && ms < minExpire {
+			minExpire = ms
+		}
+	}
+
+	w.WriteByte(24)
+	binary.Write(w, binary.LittleEndian, minExpire)
+	encodeLen(w, uint64(len(h)))
+	for _, e := range h {
+		var ttl uint64
+		if !e.ExpiryTime.IsZero() {
+			ttl = uint64(timeToMilliseconds(e.ExpiryTime)-minExpire) + 1
+		}
+		encodeLen(w, ttl)
+		encodeString(w, []byte(DataToString(e.Key)))
+		encodeString(w, []byte(DataToString(e.Value)))
+	}
+}
+
+// Write a length using the RDB length encoding
+func encodeLen(w io.Writer, l uint64) {
+	switch {
+	case l < 1<<6:
+		w.Write([]byte{byte(l)})
+	case l < 1<<14:
+		w.Write([]byte{0x40 | byte(l>>8), byte(l)})
+	case l <= math.MaxUint32:
+		w.Write([]byte{0x80})
+		binary.Write(w, binary.BigEndian, uint32(l))
+	default:
+		w.Write([]byte{0x81})
+		binary.Write(w, binary.BigEndian, l)
+	}
+}
+
+// Write a length prefixed string
+func encodeString(w io.Writer, s []byte) {
+	encodeLen(w, uint64(len(s)))
+	w.Write(s)
+}
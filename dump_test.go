@@ -0,0 +1,212 @@
+package rdbtools
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// The example of the DUMP command documentation, for a key set to 10
+var testDumpPayload = []byte("\x00\xc0\n\t\x00\xbem\x06\x89Z(\x00\n")
+
+// Append the footer of a DUMP payload to a value
+func newDumpPayload(value []byte, version uint16) []byte {
+	var buf bytes.Buffer
+	buf.Write(value)
+	binary.Write(&buf, binary.LittleEndian, version)
+	binary.Write(&buf, binary.LittleEndian, crc64(buf.Bytes()))
+	return buf.Bytes()
+}
+
+func TestDecodeDumpPayload(t *testing.T) {
+	v, err := DecodeDumpPayload(testDumpPayload)
+	ok(t, err)
+	equals(t, DumpString("10"), v)
+}
+
+func TestDecodeDumpPayloadDisabledChecksum(t *testing.T) {
+	payload := append([]byte(nil), testDumpPayload...)
+	copy(payload[len(payload)-8:], make([]byte, 8))
+
+	v, err := DecodeDumpPayload(payload)
+	ok(t, err)
+	equals(t, DumpString("10"), v)
+}
+
+func TestDecodeDumpPayloadInvalidChecksum(t *testing.T) {
+	payload := append([]byte(nil), testDumpPayload...)
+	payload[len(payload)-1]++
+
+	_, err := DecodeDumpPayload(payload)
+	equals(t, ErrInvalidChecksum, err)
+}
+
+func TestDecodeDumpPayloadInvalidVersion(t *testing.T) {
+	_, err := DecodeDumpPayload(newDumpPayload([]byte{0, 1, 'a'}, RedisRdbVersion+1))
+	equals(t, ErrInvalidRDBVersionNumber, err)
+
+	_, err = DecodeDumpPayload(newDumpPayload([]byte{0, 1, 'a'}, 0))
+	equals(t, ErrInvalidRDBVersionNumber, err)
+}
+
+func TestDecodeDumpPayloadTooShort(t *testing.T) {
+	_, err := DecodeDumpPayload(testDumpPayload[:10])
+	equals(t, ErrInvalidDumpPayload, err)
+}
+
+func TestDecodeDumpPayloadTrailingData(t *testing.T) {
+	_, err := DecodeDumpPayload(newDumpPayload([]byte{0, 1, 'a', 'b'}, 9))
+	equals(t, ErrInvalidDumpPayload, err)
+}
+
+func TestDecodeDumpPayloadUnknownValueType(t *testing.T) {
+	_, err := DecodeDumpPayload(newDumpPayload([]byte{0xF0, 1, 'a'}, 9))
+	equals(t, ErrUnknownValueType, err)
+}
+
+func TestDecodeDumpPayloadMalformed(t *testing.T) {
+	testCases := []struct {
+		name  string
+		value []byte
+		err   error
+	}{
+		{"string with a bogus 64 bit length", []byte{0, 0x81, 0x7F, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 'a'}, ErrInvalidDumpPayload},
+		{"string with a bogus 32 bit length", []byte{0, 0x80, 0xFF, 0xFF, 0xFF, 0xFF, 'a'}, ErrInvalidDumpPayload},
+		{"LZF string with a bogus length", []byte{0, 0xC3, 2, 0x80, 0xFF, 0xFF, 0xFF, 0xFF, 0, 'a'}, ErrInvalidLZFData},
+		{"list with a bogus length", []byte{1, 0x81, 0x7F, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 1, 'a'}, ErrInvalidDumpPayload},
+		{"truncated listpack", []byte{20, 7, 7, 0, 0, 0, 1, 0, 0x81}, ErrInvalidDumpPayload},
+		{"hash zipmap as an integer", []byte{9, 0xC0, 5}, ErrUnexpectedEncodedLength},
+		{"list ziplist as an integer", []byte{10, 0xC0, 5}, ErrUnexpectedEncodedLength},
+		{"intset as an integer", []byte{11, 0xC0, 5}, ErrUnexpectedEncodedLength},
+		{"sorted set ziplist as an integer", []byte{12, 0xC0, 5}, ErrUnexpectedEncodedLength},
+		{"hash ziplist as an integer", []byte{13, 0xC0, 5}, ErrUnexpectedEncodedLength},
+		{"quicklist node as an integer", []byte{14, 1, 0xC0, 5}, ErrUnexpectedEncodedLength},
+		{"hash listpack as an integer", []byte{16, 0xC0, 5}, ErrUnexpectedEncodedLength},
+		{"sorted set listpack as an integer", []byte{17, 0xC0, 5}, ErrUnexpectedEncodedLength},
+		{"quicklist packed node as an integer", []byte{18, 1, 2, 0xC0, 5}, ErrUnexpectedEncodedLength},
+		{"set listpack as an integer", []byte{20, 0xC0, 5}, ErrUnexpectedEncodedLength},
+		{"stream listpack as an integer", append(append([]byte{15, 1, 16}, make([]byte, 16)...), 0xC0, 5), ErrUnexpectedEncodedLength},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := DecodeDumpPayload(newDumpPayload(tc.value, 11))
+			equals(t, tc.err, err)
+		})
+	}
+}
+
+func TestDecodeDumpPayloadListpacks(t *testing.T) {
+	var value bytes.Buffer
+	value.WriteByte(16) // hash map in listpack encoding
+	writeString(&value, string(newListpack("foo", "bar", "baz", int64(1))))
+
+	v, err := DecodeDumpPayload(newDumpPayload(value.Bytes(), 11))
+	ok(t, err)
+	equals(t, DumpHash{
+		{Key: []byte("foo"), Value: []byte("bar")},
+		{Key: []byte("baz"), Value: int64(1)},
+	}, v)
+
+	value.Reset()
+	value.WriteByte(20) // set in listpack encoding
+	writeString(&value, string(newListpack("a", int64(2))))
+
+	v, err = DecodeDumpPayload(newDumpPayload(value.Bytes(), 11))
+	ok(t, err)
+	equals(t, DumpSet{[]byte("a"), []byte("2")}, v)
+}
+
+func TestDecodeDumpPayloadStream(t *testing.T) {
+	var value bytes.Buffer
+	value.WriteByte(21)
+	writeTestStream(&value, 3)
+
+	v, err := DecodeDumpPayload(newDumpPayload(value.Bytes(), 11))
+	ok(t, err)
+
+	s, isStream := v.(DumpStream)
+	equals(t, true, isStream)
+	equals(t, int64(2), s.Metadata.Len)
+	equals(t, 2, len(s.Entries))
+	equals(t, 1, len(s.Groups))
+}
+
+func TestEncodeDumpPayload(t *testing.T) {
+	payload, err := EncodeDumpPayload(DumpString("10"))
+	ok(t, err)
+	equals(t, []byte("\x00\x0210\t\x00"), payload[:len(payload)-8])
+
+	for _, v := range []interface{}{
+		DumpString("foobar"),
+		DumpList{[]byte("a"), []byte("b")},
+		DumpSet{[]byte("a"), []byte("b")},
+		DumpSortedSet{{Value: []byte("a"), Score: 1.5}, {Value: []byte("b"), Score: -2}},
+		DumpHash{{Key: []byte("a"), Value: []byte("1")}},
+		DumpList{},
+	} {
+		payload, err := EncodeDumpPayload(v)
+		ok(t, err)
+
+		decoded, err := DecodeDumpPayload(payload)
+		ok(t, err)
+
+		if l, isList := v.(DumpList); isList && len(l) == 0 {
+			equals(t, DumpList(nil), decoded)
+			continue
+		}
+		equals(t, v, decoded)
+	}
+}
+
+// A hash with field expiration times is encoded as type 24
+func TestEncodeDumpPayloadHashWithFieldExpiry(t *testing.T) {
+	v := DumpHash{
+		{Key: []byte("a"), Value: []byte("1"), ExpiryTime: millisecondsToTime(1700000000500)},
+		{Key: []byte("b"), Value: []byte("2")},
+		{Key: []byte("c"), Value: []byte("3"), ExpiryTime: millisecondsToTime(1700000000000)},
+	}
+
+	payload, err := EncodeDumpPayload(v)
+	ok(t, err)
+	equals(t, byte(24), payload[0])
+	equals(t, uint16(12), binary.LittleEndian.Uint16(payload[len(payload)-10:]))
+
+	decoded, err := DecodeDumpPayload(payload)
+	ok(t, err)
+	equals(t, v, decoded)
+}
+
+// A module value without a registered decoder is encoded with its raw serialization
+func TestEncodeDumpPayloadModule(t *testing.T) {
+	var value bytes.Buffer
+	value.WriteByte(7)
+	writeLen(&value, mustEncodeModuleID("nodecoder", 2))
+	writeTestModuleValue(&value)
+
+	v, err := DecodeDumpPayload(newDumpPayload(value.Bytes(), 9))
+	ok(t, err)
+
+	payload, err := EncodeDumpPayload(v)
+	ok(t, err)
+	equals(t, newDumpPayload(value.Bytes(), 9), payload)
+
+	decoded, err := DecodeDumpPayload(payload)
+	ok(t, err)
+	equals(t, v, decoded)
+}
+
+func TestEncodeDumpPayloadUnsupportedValue(t *testing.T) {
+	_, err := EncodeDumpPayload("foobar")
+	equals(t, ErrUnsupportedDumpValue, err)
+
+	_, err = EncodeDumpPayload(DumpStream{})
+	equals(t, ErrUnsupportedDumpValue, err)
+
+	// Without its raw serialization, a module value can't be encoded
+	_, err = EncodeDumpPayload(ModuleObject{ModuleName: "ReJSON-RL", Version: 3, Value: "decoded"})
+	equals(t, ErrUnsupportedDumpValue, err)
+
+	_, err = EncodeDumpPayload(ModuleObject{ModuleName: "ReJSON", Raw: []byte{moduleOpcodeEOF}})
+	equals(t, ErrUnsupportedDumpValue, err)
+}
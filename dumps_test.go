@@ -9,20 +9,17 @@ import (
 // This file contains tests for all the dumps in the dumps/ directory
 // Those dumps are taken from https://github.com/sripathikrishnan/redis-rdb-tools
 
-func mustOpen(t *testing.T, path string) *os.File {
+func doParse(t *testing.T, p Parser, ctx ParserContext, path string) {
 	f, err := os.Open(path)
 	if err != nil {
-		t.Fatalf("Error while opening file '%s'; err=%s", path, err)
+		ctx.closeChannels()
+		t.Errorf("Error while opening file '%s'; err=%s", path, err)
+		return
 	}
 
-	return f
-}
-
-func doParse(t *testing.T, p Parser, ctx ParserContext, path string) {
-	err := p.Parse(mustOpen(t, path))
+	err = p.Parse(f)
 	if err != nil {
-		ctx.closeChannels()
-		t.Fatalf("Error while parsing '%s'; err=%s", path, err)
+		t.Errorf("Error while parsing '%s'; err=%s", path, err)
 	}
 }
 
@@ -309,7 +306,7 @@ func TestDumpKeysWithExpiry(t *testing.T) {
 				break
 			}
 			equals(t, "expires_ms_precision", DataToString(v.Key.Key))
-			equals(t, "2022-12-25 10:11:12 +0000 UTC", v.Key.ExpiryTime.UTC().String())
+			equals(t, "2022-12-25 10:11:12.573 +0000 UTC", v.Key.ExpiryTime.UTC().String())
 			equals(t, "2022-12-25 10:11:12.573 UTC", DataToString(v.Value))
 		}
 
@@ -0,0 +1,120 @@
+package rdbtools
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+)
+
+// Represents a library of Redis Functions (Redis >= 7.0) or a Lua script saved by older versions.
+type FunctionLibrary struct {
+	Engine string      // The engine of the library, for example "lua"
+	Name   string      // The name of the library. For a Lua script, it is the SHA1 digest of the script.
+	Code   interface{} // The source code of the library
+}
+
+// Returns a visualization of the function library
+func (l FunctionLibrary) String() string {
+	return fmt.Sprintf("FunctionLibrary{Engine: %s, Name: %s}", l.Engine, l.Name)
+}
+
+// Read a library saved with the FUNCTION2 opcode (Redis >= 7.0).
+//
+// Only the code is saved, the engine and the name come from its shebang line:
+//
+//	#!<engine> name=<name> [<arg>=<value> ...]
+func (p *parser) readFunction2(r io.Reader) error {
+	code, err := p.readString(r)
+	if err != nil {
+		return err
+	}
+
+	lib, err := parseFunctionLibraryHeader([]byte(DataToString(code)))
+	if err != nil {
+		return err
+	}
+	lib.Code = code
+
+	if err := p.h.FunctionLibrary(lib); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Read a library saved with the FUNCTION_PRE_GA opcode (Redis 7.0 release candidates),
+// where the name, the engine and the description precede the code.
+func (p *parser) readFunctionPreGA(r io.Reader) error {
+	name, err := p.readString(r)
+	if err != nil {
+		return err
+	}
+
+	engine, err := p.readString(r)
+	if err != nil {
+		return err
+	}
+
+	hasDesc, e, err := p.readLen(r)
+	if err != nil {
+		return err
+	}
+	if e {
+		return ErrUnexpectedEncodedLength
+	}
+	if hasDesc != 0 {
+		if _, err := p.readString(r); err != nil {
+			return err
+		}
+	}
+
+	code, err := p.readString(r)
+	if err != nil {
+		return err
+	}
+
+	if err := p.h.FunctionLibrary(FunctionLibrary{Engine: DataToString(engine), Name: DataToString(name), Code: code}); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Send the Lua script saved in a "lua" auxiliary field (Redis < 7.0)
+func (p *parser) sendLuaScript(code interface{}) error {
+	sum := sha1.Sum([]byte(DataToString(code)))
+	return p.h.FunctionLibrary(FunctionLibrary{Engine: "lua", Name: hex.EncodeToString(sum[:]), Code: code})
+}
+
+func parseFunctionLibraryHeader(code []byte) (FunctionLibrary, error) {
+	var lib FunctionLibrary
+
+	if !bytes.HasPrefix(code, []byte("#!")) {
+		return lib, ErrInvalidFunctionLibrary
+	}
+
+	line := code[2:]
+	if i := bytes.IndexByte(line, '\n'); i >= 0 {
+		line = line[:i]
+	}
+
+	fields := bytes.Fields(line)
+	if len(fields) == 0 {
+		return lib, ErrInvalidFunctionLibrary
+	}
+	lib.Engine = string(fields[0])
+
+	for _, f := range fields[1:] {
+		if bytes.HasPrefix(f, []byte("name=")) {
+			lib.Name = string(f[len("name="):])
+		}
+	}
+
+	if lib.Name == "" {
+		return lib, ErrInvalidFunctionLibrary
+	}
+
+	return lib, nil
+}
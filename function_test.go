@@ -0,0 +1,149 @@
+package rdbtools
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"testing"
+)
+
+const testFunctionLibraryCode = "#!lua name=mylib\nredis.register_function('f', function() return 1 end)"
+
+func TestFunctionLibraryString(t *testing.T) {
+	l := FunctionLibrary{Engine: "lua", Name: "mylib"}
+	equals(t, "FunctionLibrary{Engine: lua, Name: mylib}", l.String())
+}
+
+func TestReadFunction2(t *testing.T) {
+	var buffer bytes.Buffer
+
+	br := bufio.NewWriter(&buffer)
+	writeString(br, testFunctionLibraryCode)
+	br.Flush()
+
+	ctx := ParserContext{FunctionLibraryCh: make(chan FunctionLibrary)}
+	p := &parser{h: newChannelHandler(ctx)}
+
+	go func() {
+		l := <-ctx.FunctionLibraryCh
+		equals(t, "lua", l.Engine)
+		equals(t, "mylib", l.Name)
+		equals(t, testFunctionLibraryCode, DataToString(l.Code))
+	}()
+
+	err := p.readFunction2(bufio.NewReader(&buffer))
+	ok(t, err)
+}
+
+func TestReadFunction2NoData(t *testing.T) {
+	var buffer bytes.Buffer
+
+	p := &parser{}
+	err := p.readFunction2(bufio.NewReader(&buffer))
+	equals(t, io.EOF, err)
+}
+
+func TestReadFunction2InvalidHeader(t *testing.T) {
+	var buffer bytes.Buffer
+
+	br := bufio.NewWriter(&buffer)
+	writeString(br, "return 1")
+	br.Flush()
+
+	p := &parser{}
+	err := p.readFunction2(bufio.NewReader(&buffer))
+	equals(t, ErrInvalidFunctionLibrary, err)
+}
+
+func TestReadFunctionPreGA(t *testing.T) {
+	var buffer bytes.Buffer
+
+	br := bufio.NewWriter(&buffer)
+	writeString(br, "mylib")
+	writeString(br, "LUA")
+	writeLen(br, 1)
+	writeString(br, "my library")
+	writeString(br, "redis.register_function('f', function() return 1 end)")
+	br.Flush()
+
+	ctx := ParserContext{FunctionLibraryCh: make(chan FunctionLibrary)}
+	p := &parser{h: newChannelHandler(ctx)}
+
+	go func() {
+		l := <-ctx.FunctionLibraryCh
+		equals(t, "LUA", l.Engine)
+		equals(t, "mylib", l.Name)
+		equals(t, "redis.register_function('f', function() return 1 end)", DataToString(l.Code))
+	}()
+
+	err := p.readFunctionPreGA(bufio.NewReader(&buffer))
+	ok(t, err)
+}
+
+func TestReadFunctionPreGANoDescription(t *testing.T) {
+	var buffer bytes.Buffer
+
+	br := bufio.NewWriter(&buffer)
+	writeString(br, "mylib")
+	writeString(br, "LUA")
+	writeLen(br, 0)
+	br.Flush()
+
+	p := &parser{}
+	err := p.readFunctionPreGA(bufio.NewReader(&buffer))
+	equals(t, io.EOF, err)
+}
+
+func TestReadFunctionPreGAEncodedLen(t *testing.T) {
+	var buffer bytes.Buffer
+
+	br := bufio.NewWriter(&buffer)
+	writeString(br, "mylib")
+	writeString(br, "LUA")
+	br.WriteByte(0xC0)
+	br.Flush()
+
+	p := &parser{}
+	err := p.readFunctionPreGA(bufio.NewReader(&buffer))
+	equals(t, ErrUnexpectedEncodedLength, err)
+}
+
+func TestReadAuxFieldLuaScript(t *testing.T) {
+	var buffer bytes.Buffer
+
+	br := bufio.NewWriter(&buffer)
+	writeString(br, "lua")
+	writeString(br, "return 1")
+	br.Flush()
+
+	ctx := ParserContext{
+		AuxFieldCh:        make(chan AuxField),
+		FunctionLibraryCh: make(chan FunctionLibrary),
+	}
+	p := &parser{h: newChannelHandler(ctx)}
+
+	go func() {
+		f := <-ctx.AuxFieldCh
+		equals(t, "lua", DataToString(f.Key))
+
+		l := <-ctx.FunctionLibraryCh
+		equals(t, "lua", l.Engine)
+		equals(t, "e0e1f9fabfc9d4800c877a703b823ac0578ff8db", l.Name)
+		equals(t, "return 1", DataToString(l.Code))
+	}()
+
+	err := p.readAuxField(bufio.NewReader(&buffer))
+	ok(t, err)
+}
+
+func TestParseFunctionLibraryHeader(t *testing.T) {
+	l, err := parseFunctionLibraryHeader([]byte("#!lua engine-arg=1 name=mylib\nreturn"))
+	ok(t, err)
+	equals(t, FunctionLibrary{Engine: "lua", Name: "mylib"}, l)
+
+	_, err = parseFunctionLibraryHeader([]byte("#!\nreturn"))
+	equals(t, ErrInvalidFunctionLibrary, err)
+
+	_, err = parseFunctionLibraryHeader([]byte("#!lua\nreturn"))
+	equals(t, ErrInvalidFunctionLibrary, err)
+}
@@ -0,0 +1,267 @@
+package rdbtools
+
+import "context"
+
+// Handler receives the data of a RDB file. Its methods are called synchronously by the parser,
+// in the order of the file, and returning an error stops the parsing with this error.
+//
+// The elements of a collection are given between its Start and End methods, as they are read.
+// With WithKeyDBMemberExpiries, those of sets, hashes and sorted sets are held until the KeyDB
+// member expiry times following them are read.
+// Embed NopHandler to only implement the methods you care about.
+type Handler interface {
+	StartRDB(h Header) error
+	AuxField(f AuxField) error
+	ModuleAux(a ModuleAux) error
+	FunctionLibrary(l FunctionLibrary) error
+	SlotInfo(s SlotInfo) error
+
+	StartDatabase(db int) error
+	DatabaseMetadata(md DatabaseMetadata) error
+
+	String(o StringObject) error
+
+	StartList(md ListMetadata) error
+	ListElement(e interface{}) error
+	EndList(key KeyObject) error
+
+	StartSet(md SetMetadata) error
+	SetMember(e interface{}) error
+	EndSet(key KeyObject) error
+
+	StartHash(md HashMetadata) error
+	HashEntry(e HashEntry) error
+	EndHash(key KeyObject) error
+
+	StartSortedSet(md SortedSetMetadata) error
+	SortedSetEntry(e SortedSetEntry) error
+	EndSortedSet(key KeyObject) error
+
+	StartStream(md StreamMetadata) error
+	StreamEntry(e StreamEntry) error
+	StreamConsumerGroup(g StreamConsumerGroup) error
+	EndStream(key KeyObject) error
+
+	Module(o ModuleObject) error
+	MemberExpiry(m MemberExpiry) error
+
+	EndDatabase(db int) error
+	EndRDB() error
+}
+
+// NopHandler is a Handler ignoring everything
+type NopHandler struct{}
+
+func (NopHandler) StartRDB(h Header) error                         { return nil }
+func (NopHandler) AuxField(f AuxField) error                       { return nil }
+func (NopHandler) ModuleAux(a ModuleAux) error                     { return nil }
+func (NopHandler) FunctionLibrary(l FunctionLibrary) error         { return nil }
+func (NopHandler) SlotInfo(s SlotInfo) error                       { return nil }
+func (NopHandler) StartDatabase(db int) error                      { return nil }
+func (NopHandler) DatabaseMetadata(md DatabaseMetadata) error      { return nil }
+func (NopHandler) String(o StringObject) error                     { return nil }
+func (NopHandler) StartList(md ListMetadata) error                 { return nil }
+func (NopHandler) ListElement(e interface{}) error                 { return nil }
+func (NopHandler) EndList(key KeyObject) error                     { return nil }
+func (NopHandler) StartSet(md SetMetadata) error                   { return nil }
+func (NopHandler) SetMember(e interface{}) error                   { return nil }
+func (NopHandler) EndSet(key KeyObject) error                      { return nil }
+func (NopHandler) StartHash(md HashMetadata) error                 { return nil }
+func (NopHandler) HashEntry(e HashEntry) error                     { return nil }
+func (NopHandler) EndHash(key KeyObject) error                     { return nil }
+func (NopHandler) StartSortedSet(md SortedSetMetadata) error       { return nil }
+func (NopHandler) SortedSetEntry(e SortedSetEntry) error           { return nil }
+func (NopHandler) EndSortedSet(key KeyObject) error                { return nil }
+func (NopHandler) StartStream(md StreamMetadata) error             { return nil }
+func (NopHandler) StreamEntry(e StreamEntry) error                 { return nil }
+func (NopHandler) StreamConsumerGroup(g StreamConsumerGroup) error { return nil }
+func (NopHandler) EndStream(key KeyObject) error                   { return nil }
+func (NopHandler) Module(o ModuleObject) error                     { return nil }
+func (NopHandler) MemberExpiry(m MemberExpiry) error               { return nil }
+func (NopHandler) EndDatabase(db int) error                        { return nil }
+func (NopHandler) EndRDB() error                                   { return nil }
+
+// channelHandler is the Handler sending the data on the channels of a ParserContext
+type channelHandler struct {
+	NopHandler
+	ctx    ParserContext
+	cancel context.Context // Stops the sends blocked on a channel
+
+	collection     KeyObject // The key of the collection being read
+	collectionType string    // The type of the collection being read
+}
+
+func newChannelHandler(ctx ParserContext) *channelHandler {
+	return &channelHandler{ctx: ctx, cancel: context.Background()}
+}
+
+// Send v on ch unless ch is nil, until the context of the parser is done
+func send[T any](c *channelHandler, ch chan T, v T) error {
+	if ch == nil {
+		return nil
+	}
+
+	select {
+	case ch <- v:
+		return nil
+	case <-c.cancel.Done():
+		return c.cancel.Err()
+	}
+}
+
+func (c *channelHandler) StartRDB(h Header) error {
+	return send(c, c.ctx.HeaderCh, h)
+}
+
+func (c *channelHandler) AuxField(f AuxField) error {
+	return send(c, c.ctx.AuxFieldCh, f)
+}
+
+func (c *channelHandler) ModuleAux(a ModuleAux) error {
+	return send(c, c.ctx.ModuleAuxCh, a)
+}
+
+func (c *channelHandler) FunctionLibrary(l FunctionLibrary) error {
+	return send(c, c.ctx.FunctionLibraryCh, l)
+}
+
+func (c *channelHandler) SlotInfo(s SlotInfo) error {
+	return send(c, c.ctx.SlotInfoCh, s)
+}
+
+func (c *channelHandler) StartDatabase(db int) error {
+	return send(c, c.ctx.DbCh, db)
+}
+
+func (c *channelHandler) DatabaseMetadata(md DatabaseMetadata) error {
+	return send(c, c.ctx.DatabaseMetadataCh, md)
+}
+
+func (c *channelHandler) String(o StringObject) error {
+	return send(c, c.ctx.StringObjectCh, o)
+}
+
+func (c *channelHandler) StartList(md ListMetadata) error {
+	c.collection, c.collectionType = md.Key, "list"
+
+	return send(c, c.ctx.ListMetadataCh, md)
+}
+
+func (c *channelHandler) ListElement(e interface{}) error {
+	if err := c.sendCollectionEvent(CollectionEvent{Key: c.collection, Type: c.collectionType, Value: e}); err != nil {
+		return err
+	}
+	return send(c, c.ctx.ListDataCh, e)
+}
+
+func (c *channelHandler) StartSet(md SetMetadata) error {
+	c.collection, c.collectionType = md.Key, "set"
+
+	return send(c, c.ctx.SetMetadataCh, md)
+}
+
+func (c *channelHandler) SetMember(e interface{}) error {
+	if err := c.sendCollectionEvent(CollectionEvent{Key: c.collection, Type: c.collectionType, Value: e}); err != nil {
+		return err
+	}
+	return send(c, c.ctx.SetDataCh, e)
+}
+
+func (c *channelHandler) StartHash(md HashMetadata) error {
+	c.collection, c.collectionType = md.Key, "hash"
+
+	return send(c, c.ctx.HashMetadataCh, md)
+}
+
+func (c *channelHandler) HashEntry(e HashEntry) error {
+	if err := c.sendCollectionEvent(CollectionEvent{Key: c.collection, Type: c.collectionType, Value: e}); err != nil {
+		return err
+	}
+	return send(c, c.ctx.HashDataCh, e)
+}
+
+func (c *channelHandler) StartSortedSet(md SortedSetMetadata) error {
+	c.collection, c.collectionType = md.Key, "zset"
+
+	return send(c, c.ctx.SortedSetMetadataCh, md)
+}
+
+func (c *channelHandler) SortedSetEntry(e SortedSetEntry) error {
+	if err := c.sendCollectionEvent(CollectionEvent{Key: c.collection, Type: c.collectionType, Value: e}); err != nil {
+		return err
+	}
+	return send(c, c.ctx.SortedSetEntriesCh, e)
+}
+
+func (c *channelHandler) StartStream(md StreamMetadata) error {
+	c.collection, c.collectionType = md.Key, "stream"
+
+	return send(c, c.ctx.StreamMetadataCh, md)
+}
+
+func (c *channelHandler) StreamEntry(e StreamEntry) error {
+	if err := c.sendCollectionEvent(CollectionEvent{Key: c.collection, Type: c.collectionType, Value: e}); err != nil {
+		return err
+	}
+	return send(c, c.ctx.StreamEntriesCh, e)
+}
+
+func (c *channelHandler) StreamConsumerGroup(g StreamConsumerGroup) error {
+	if err := c.sendCollectionEvent(CollectionEvent{Key: c.collection, Type: c.collectionType, Value: g}); err != nil {
+		return err
+	}
+	return send(c, c.ctx.StreamConsumerGroupsCh, g)
+}
+
+func (c *channelHandler) Module(o ModuleObject) error {
+	return send(c, c.ctx.ModuleObjectCh, o)
+}
+
+func (c *channelHandler) MemberExpiry(m MemberExpiry) error {
+	return send(c, c.ctx.MemberExpiryCh, m)
+}
+
+func (c *channelHandler) EndList(key KeyObject) error {
+	return c.sendCollectionEvent(CollectionEvent{Key: key, Type: "list", End: true})
+}
+
+func (c *channelHandler) EndSet(key KeyObject) error {
+	return c.sendCollectionEvent(CollectionEvent{Key: key, Type: "set", End: true})
+}
+
+func (c *channelHandler) EndHash(key KeyObject) error {
+	return c.sendCollectionEvent(CollectionEvent{Key: key, Type: "hash", End: true})
+}
+
+func (c *channelHandler) EndSortedSet(key KeyObject) error {
+	return c.sendCollectionEvent(CollectionEvent{Key: key, Type: "zset", End: true})
+}
+
+func (c *channelHandler) EndStream(key KeyObject) error {
+	return c.sendCollectionEvent(CollectionEvent{Key: key, Type: "stream", End: true})
+}
+
+func (c *channelHandler) sendCollectionEvent(e CollectionEvent) error {
+	return send(c, c.ctx.CollectionEventCh, e)
+}
+
+func (c *channelHandler) EndRDB() error {
+	c.ctx.closeChannels()
+	return nil
+}
+
+// Close the channels and send the error stopping the parser.
+// ErrCh is buffered (see NewParser), so the send doesn't block even if nobody reads it anymore.
+func (c *channelHandler) fail(err error) {
+	errCh := c.ctx.ErrCh
+	c.ctx.ErrCh = nil
+	c.ctx.closeChannels()
+
+	if errCh != nil {
+		select {
+		case errCh <- err:
+		default:
+		}
+		close(errCh)
+	}
+}
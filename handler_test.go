@@ -0,0 +1,174 @@
+package rdbtools
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// A handler recording the calls it receives
+type recordingHandler struct {
+	NopHandler
+	calls []string
+	err   error // Returned by the String method
+}
+
+func (h *recordingHandler) record(format string, args ...interface{}) error {
+	h.calls = append(h.calls, fmt.Sprintf(format, args...))
+	return nil
+}
+
+func (h *recordingHandler) StartRDB(hdr Header) error {
+	return h.record("StartRDB %s %d", hdr.Flavour, hdr.Version)
+}
+
+func (h *recordingHandler) StartDatabase(db int) error {
+	return h.record("StartDatabase %d", db)
+}
+
+func (h *recordingHandler) String(o StringObject) error {
+	h.record("String %s", DataToString(o.Key))
+	return h.err
+}
+
+func (h *recordingHandler) StartList(md ListMetadata) error {
+	return h.record("StartList %s", DataToString(md.Key))
+}
+
+func (h *recordingHandler) ListElement(e interface{}) error {
+	return h.record("ListElement %s", DataToString(e))
+}
+
+func (h *recordingHandler) EndList(key KeyObject) error {
+	return h.record("EndList %s", DataToString(key))
+}
+
+func (h *recordingHandler) AuxField(f AuxField) error {
+	return h.record("%s", f)
+}
+
+func (h *recordingHandler) StartSet(md SetMetadata) error {
+	return h.record("StartSet %s", DataToString(md.Key))
+}
+
+func (h *recordingHandler) SetMember(e interface{}) error {
+	return h.record("SetMember %s", DataToString(e))
+}
+
+func (h *recordingHandler) EndSet(key KeyObject) error {
+	return h.record("EndSet %s", DataToString(key))
+}
+
+func (h *recordingHandler) MemberExpiry(m MemberExpiry) error {
+	return h.record("%s", m)
+}
+
+func (h *recordingHandler) StartHash(md HashMetadata) error {
+	return h.record("StartHash %s", DataToString(md.Key))
+}
+
+func (h *recordingHandler) HashEntry(e HashEntry) error {
+	return h.record("%s", e)
+}
+
+func (h *recordingHandler) EndHash(key KeyObject) error {
+	return h.record("EndHash %s", DataToString(key))
+}
+
+func (h *recordingHandler) StartSortedSet(md SortedSetMetadata) error {
+	return h.record("StartSortedSet %s", DataToString(md.Key))
+}
+
+func (h *recordingHandler) SortedSetEntry(e SortedSetEntry) error {
+	return h.record("%s", e)
+}
+
+func (h *recordingHandler) EndSortedSet(key KeyObject) error {
+	return h.record("EndSortedSet %s", DataToString(key))
+}
+
+func (h *recordingHandler) EndDatabase(db int) error {
+	return h.record("EndDatabase %d", db)
+}
+
+func (h *recordingHandler) EndRDB() error {
+	return h.record("EndRDB")
+}
+
+func writeHandlerTestRDB(w *bufio.Writer) {
+	w.WriteString("REDIS0006")
+	w.WriteByte(0xFE) // next database byte
+	w.WriteByte(0)    // database number
+	w.WriteByte(0)    // string
+	writeString(w, "a")
+	writeString(w, "foo")
+	w.WriteByte(0xFE) // next database byte
+	w.WriteByte(1)    // database number
+	w.WriteByte(1)    // list
+	writeString(w, "b")
+	writeLen(w, 2)
+	writeString(w, "x")
+	writeString(w, "y")
+	w.WriteByte(0xFF) // end of file
+	w.Write(make([]byte, 8))
+	w.Flush()
+}
+
+func TestNewHandlerParser(t *testing.T) {
+	var buffer bytes.Buffer
+	writeHandlerTestRDB(bufio.NewWriter(&buffer))
+
+	h := &recordingHandler{}
+	err := NewHandlerParser(h).Parse(&buffer)
+	ok(t, err)
+	equals(t, []string{
+		"StartRDB Redis 6",
+		"StartDatabase 0",
+		"String a",
+		"EndDatabase 0",
+		"StartDatabase 1",
+		"StartList b",
+		"ListElement x",
+		"ListElement y",
+		"EndList b",
+		"EndDatabase 1",
+		"EndRDB",
+	}, h.calls)
+}
+
+func TestNewHandlerParserError(t *testing.T) {
+	var buffer bytes.Buffer
+	writeHandlerTestRDB(bufio.NewWriter(&buffer))
+
+	handlerErr := errors.New("stop")
+	h := &recordingHandler{err: handlerErr}
+	err := NewHandlerParser(h).Parse(&buffer)
+	equals(t, handlerErr, err)
+	equals(t, []string{"StartRDB Redis 6", "StartDatabase 0", "String a"}, h.calls)
+}
+
+// A handler keeping the keys of the strings
+type stringKeysHandler struct {
+	NopHandler
+	keys []KeyObject
+}
+
+func (h *stringKeysHandler) String(o StringObject) error {
+	h.keys = append(h.keys, o.Key)
+	return nil
+}
+
+func TestNewHandlerParserExpireAtSnapshotTime(t *testing.T) {
+	var buffer bytes.Buffer
+	ctime := writeSnapshotTimeRDB(&buffer)
+
+	h := &stringKeysHandler{}
+	err := NewHandlerParser(h, WithExpireAtSnapshotTime()).Parse(&buffer)
+	ok(t, err)
+
+	equals(t, 1, len(h.keys))
+	equals(t, ctime, h.keys[0].ExpiryReference)
+	equals(t, false, h.keys[0].Expired())
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/binary"
 	"fmt"
 	"io"
+	"time"
 )
 
 // Represents the metadata of a hash, which is the key and the hash length
@@ -21,12 +22,17 @@ func (m HashMetadata) String() string {
 
 // Represents an entry in a hash
 type HashEntry struct {
-	Key   interface{}
-	Value interface{}
+	Key        interface{}
+	Value      interface{}
+	ExpiryTime time.Time // The expiry time of the field (Redis >= 7.4, Valkey >= 9.0 or KeyDB). If none, this object IsZero() method will return true
 }
 
 // Returns a string visualization of the entry
 func (e HashEntry) String() string {
+	if !e.ExpiryTime.IsZero() {
+		return fmt.Sprintf("HashEntry{Key: %s, Value: %s, ExpiryTime: %s}", DataToString(e.Key), DataToString(e.Value), e.ExpiryTime)
+	}
+
 	return fmt.Sprintf("HashEntry{Key: %s, Value: %s}", DataToString(e.Key), DataToString(e.Value))
 }
 
@@ -39,11 +45,11 @@ func (p *parser) readHashMap(key KeyObject, r io.Reader) error {
 		return ErrUnexpectedEncodedLength
 	}
 
-	if p.ctx.HashMetadataCh != nil {
-		p.ctx.HashMetadataCh <- HashMetadata{Key: key, Len: l}
+	if err := p.h.StartHash(HashMetadata{Key: key, Len: int64(l)}); err != nil {
+		return err
 	}
 
-	for i := int64(0); i < l; i++ {
+	for i := uint64(0); i < l; i++ {
 		entryKey, err := p.readString(r)
 		if err != nil {
 			return err
@@ -54,8 +60,8 @@ func (p *parser) readHashMap(key KeyObject, r io.Reader) error {
 			return err
 		}
 
-		if p.ctx.HashDataCh != nil {
-			p.ctx.HashDataCh <- HashEntry{Key: entryKey, Value: entryValue}
+		if err := p.h.HashEntry(HashEntry{Key: entryKey, Value: entryValue}); err != nil {
+			return err
 		}
 	}
 
@@ -63,15 +69,15 @@ func (p *parser) readHashMap(key KeyObject, r io.Reader) error {
 }
 
 func (p *parser) readHashMapInZipList(key KeyObject, r io.Reader) error {
-	data, err := p.readString(r)
+	data, err := p.readBytesString(r)
 	if err != nil {
 		return err
 	}
 
 	var entryKey interface{} = nil
 	onLenCallback := func(length int64) error {
-		if p.ctx.HashMetadataCh != nil {
-			p.ctx.HashMetadataCh <- HashMetadata{Key: key, Len: length / 2}
+		if err := p.h.StartHash(HashMetadata{Key: key, Len: length / 2}); err != nil {
+			return err
 		}
 		return nil
 	}
@@ -79,14 +85,14 @@ func (p *parser) readHashMapInZipList(key KeyObject, r io.Reader) error {
 		if entryKey == nil {
 			entryKey = e
 		} else {
-			if p.ctx.HashDataCh != nil {
-				p.ctx.HashDataCh <- HashEntry{Key: entryKey, Value: e}
+			if err := p.h.HashEntry(HashEntry{Key: entryKey, Value: e}); err != nil {
+				return err
 			}
 			entryKey = nil
 		}
 		return nil
 	}
-	dr := bufio.NewReader(bytes.NewReader(data.([]byte)))
+	dr := bufio.NewReader(bytes.NewReader(data))
 
 	if err := p.readZipList(dr, onLenCallback, onElementCallback); err != nil {
 		return err
@@ -95,6 +101,204 @@ func (p *parser) readHashMapInZipList(key KeyObject, r io.Reader) error {
 	return nil
 }
 
+// Read a hash map encoded as a listpack (Redis >= 7.0)
+func (p *parser) readHashMapInListpack(key KeyObject, r io.Reader) error {
+	data, err := p.readBytesString(r)
+	if err != nil {
+		return err
+	}
+
+	var entryKey interface{} = nil
+	onLenCallback := func(length int64) error {
+		if err := p.h.StartHash(HashMetadata{Key: key, Len: length / 2}); err != nil {
+			return err
+		}
+		return nil
+	}
+	onElementCallback := func(e interface{}) error {
+		if entryKey == nil {
+			entryKey = e
+		} else {
+			if err := p.h.HashEntry(HashEntry{Key: entryKey, Value: e}); err != nil {
+				return err
+			}
+			entryKey = nil
+		}
+		return nil
+	}
+	dr := bufio.NewReader(bytes.NewReader(data))
+
+	if err := p.readListpack(dr, onLenCallback, onElementCallback); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Read a hash map with field expiration times (Redis >= 7.4).
+//
+// Each field is preceded by its expiry time: if preGA is true the time is absolute,
+// otherwise it is relative to the minimum expiry time of the hash, which precedes the hash length.
+// In both cases, 0 means the field doesn't expire.
+func (p *parser) readHashMapWithMetadata(key KeyObject, r io.Reader, preGA bool) error {
+	var minExpire int64
+	if !preGA {
+		if err := binary.Read(r, binary.LittleEndian, &minExpire); err != nil {
+			return err
+		}
+	}
+
+	l, e, err := p.readLen(r)
+	if err != nil {
+		return err
+	}
+	if e {
+		return ErrUnexpectedEncodedLength
+	}
+
+	if err := p.h.StartHash(HashMetadata{Key: key, Len: int64(l)}); err != nil {
+		return err
+	}
+
+	for i := uint64(0); i < l; i++ {
+		ttl, e, err := p.readLen(r)
+		if err != nil {
+			return err
+		}
+		if e {
+			return ErrUnexpectedEncodedLength
+		}
+
+		entryKey, err := p.readString(r)
+		if err != nil {
+			return err
+		}
+
+		entryValue, err := p.readString(r)
+		if err != nil {
+			return err
+		}
+
+		entry := HashEntry{Key: entryKey, Value: entryValue}
+		if ttl != 0 {
+			if !preGA {
+				ttl += uint64(minExpire) - 1
+			}
+			entry.ExpiryTime = millisecondsToTime(int64(ttl))
+		}
+
+		if err := p.h.HashEntry(entry); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Read a hash map with field expiration times encoded as a listpack (Redis >= 7.4).
+//
+// The listpack holds field, value and absolute expiry time triplets, with an expiry time of 0
+// if the field doesn't expire. Unless preGA is true, the minimum expiry time of the hash precedes the listpack.
+func (p *parser) readHashMapInListpackEx(key KeyObject, r io.Reader, preGA bool) error {
+	if !preGA {
+		var minExpire int64
+		if err := binary.Read(r, binary.LittleEndian, &minExpire); err != nil {
+			return err
+		}
+	}
+
+	data, err := p.readBytesString(r)
+	if err != nil {
+		return err
+	}
+
+	var entry *HashEntry
+	var hasValue bool
+	onLenCallback := func(length int64) error {
+		if err := p.h.StartHash(HashMetadata{Key: key, Len: length / 3}); err != nil {
+			return err
+		}
+		return nil
+	}
+	onElementCallback := func(e interface{}) error {
+		switch {
+		case entry == nil:
+			entry = &HashEntry{Key: e}
+		case !hasValue:
+			entry.Value = e
+			hasValue = true
+		default:
+			ttl, ok := e.(int64)
+			if !ok {
+				return ErrUnexpectedListpackEncoding
+			}
+			if ttl != 0 {
+				entry.ExpiryTime = millisecondsToTime(ttl)
+			}
+
+			if err := p.h.HashEntry(*entry); err != nil {
+				return err
+			}
+			entry, hasValue = nil, false
+		}
+		return nil
+	}
+	dr := bufio.NewReader(bytes.NewReader(data))
+
+	if err := p.readListpack(dr, onLenCallback, onElementCallback); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Read a hash map with field expiration times written by Valkey >= 9.0, which uses
+// the value type 22 of the Redis 7.4 release candidates with another encoding.
+//
+// Each field is preceded by its absolute expiry time in milliseconds, as a little endian
+// 64 bit integer which is -1 if the field doesn't expire.
+func (p *parser) readValkeyHashMap(key KeyObject, r io.Reader) error {
+	l, e, err := p.readLen(r)
+	if err != nil {
+		return err
+	}
+	if e {
+		return ErrUnexpectedEncodedLength
+	}
+
+	if err := p.h.StartHash(HashMetadata{Key: key, Len: int64(l)}); err != nil {
+		return err
+	}
+
+	for i := uint64(0); i < l; i++ {
+		var expiryTime int64
+		if err := binary.Read(r, binary.LittleEndian, &expiryTime); err != nil {
+			return err
+		}
+
+		entryKey, err := p.readString(r)
+		if err != nil {
+			return err
+		}
+
+		entryValue, err := p.readString(r)
+		if err != nil {
+			return err
+		}
+
+		entry := HashEntry{Key: entryKey, Value: entryValue}
+		if expiryTime >= 0 {
+			entry.ExpiryTime = millisecondsToTime(expiryTime)
+		}
+
+		if err := p.h.HashEntry(entry); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func readZipMapLength(r io.Reader, b byte) (int64, error) {
 	var l uint32
 	switch b {
@@ -111,12 +315,12 @@ func readZipMapLength(r io.Reader, b byte) (int64, error) {
 
 // Read a hash map encoded as a zipmap (Redis < 2.6)
 func (p *parser) readZipMap(key KeyObject, r io.Reader) error {
-	data, err := p.readString(r)
+	data, err := p.readBytesString(r)
 	if err != nil {
 		return err
 	}
 
-	dr := bufio.NewReader(bytes.NewReader(data.([]byte)))
+	dr := bufio.NewReader(bytes.NewReader(data))
 
 	// Hash map length, valid only when < 254
 	mapLen, err := dr.ReadByte()
@@ -139,8 +343,8 @@ func (p *parser) readZipMap(key KeyObject, r io.Reader) error {
 	if mapLen >= 254 {
 		results = make([]HashEntry, 0)
 	} else {
-		if p.ctx.HashMetadataCh != nil {
-			p.ctx.HashMetadataCh <- HashMetadata{Key: key, Len: int64(mapLen)}
+		if err := p.h.StartHash(HashMetadata{Key: key, Len: int64(mapLen)}); err != nil {
+			return err
 		}
 	}
 
@@ -151,7 +355,7 @@ func (p *parser) readZipMap(key KeyObject, r io.Reader) error {
 			return err
 		}
 
-		entryKey, err := readBytes(dr, l)
+		entryKey, err := readBytes(dr, uint64(l))
 		if err != nil {
 			return err
 		}
@@ -173,14 +377,14 @@ func (p *parser) readZipMap(key KeyObject, r io.Reader) error {
 			return err
 		}
 
-		entryValue, err := readBytes(dr, l)
+		entryValue, err := readBytes(dr, uint64(l))
 		if err != nil {
 			return err
 		}
 
 		// skip if necessary
 		if free > 0 {
-			if _, err = readBytes(dr, int64(free)); err != nil {
+			if _, err = readBytes(dr, uint64(free)); err != nil {
 				return err
 			}
 		}
@@ -188,8 +392,8 @@ func (p *parser) readZipMap(key KeyObject, r io.Reader) error {
 		if mapLen >= 254 {
 			results = append(results, HashEntry{Key: entryKey, Value: entryValue})
 		} else {
-			if p.ctx.HashDataCh != nil {
-				p.ctx.HashDataCh <- HashEntry{Key: entryKey, Value: entryValue}
+			if err := p.h.HashEntry(HashEntry{Key: entryKey, Value: entryValue}); err != nil {
+				return err
 			}
 		}
 
@@ -200,12 +404,12 @@ func (p *parser) readZipMap(key KeyObject, r io.Reader) error {
 	}
 
 	if mapLen >= 254 {
-		if p.ctx.HashMetadataCh != nil {
-			p.ctx.HashMetadataCh <- HashMetadata{Key: key, Len: int64(len(results))}
+		if err := p.h.StartHash(HashMetadata{Key: key, Len: int64(len(results))}); err != nil {
+			return err
 		}
-		if p.ctx.HashDataCh != nil {
-			for _, e := range results {
-				p.ctx.HashDataCh <- e
+		for _, e := range results {
+			if err := p.h.HashEntry(e); err != nil {
+				return err
 			}
 		}
 	}
@@ -28,7 +28,7 @@ func TestReadHashMap(t *testing.T) {
 		HashMetadataCh: make(chan HashMetadata),
 		HashDataCh:     make(chan HashEntry),
 	}
-	p := &parser{ctx: ctx}
+	p := &parser{h: newChannelHandler(ctx)}
 
 	go readAndNotify(t, &buffer, "hashmap", p.readHashMap)
 
@@ -75,7 +75,7 @@ func TestReadHashMapNoEntryKey(t *testing.T) {
 	br.Flush()
 
 	ctx := ParserContext{HashMetadataCh: make(chan HashMetadata)}
-	p := &parser{ctx: ctx}
+	p := &parser{h: newChannelHandler(ctx)}
 
 	go func() {
 		md := <-ctx.HashMetadataCh
@@ -97,7 +97,7 @@ func TestReadHashMapNoEntryValue(t *testing.T) {
 	br.Flush()
 
 	ctx := ParserContext{HashMetadataCh: make(chan HashMetadata, 1)}
-	p := &parser{ctx: ctx}
+	p := &parser{h: newChannelHandler(ctx)}
 
 	go func() {
 		md := <-ctx.HashMetadataCh
@@ -131,7 +131,7 @@ func TestReadHashMapInZipList(t *testing.T) {
 		HashMetadataCh: make(chan HashMetadata),
 		HashDataCh:     make(chan HashEntry),
 	}
-	p := &parser{ctx: ctx}
+	p := &parser{h: newChannelHandler(ctx)}
 
 	go readAndNotify(t, &buffer, "hashmap", p.readHashMapInZipList)
 
@@ -171,6 +171,252 @@ func TestReadHashMapInZipListEmptyZipList(t *testing.T) {
 	equals(t, "unexpected EOF", err.Error())
 }
 
+func TestReadHashMapInListpack(t *testing.T) {
+	var buffer bytes.Buffer
+	br := bufio.NewWriter(&buffer)
+
+	lp := newListpack("foo", "bar", "baz", int64(1))
+	br.WriteByte(byte(len(lp))) // String length
+	br.Write(lp)
+	br.Flush()
+
+	ctx := ParserContext{
+		HashMetadataCh: make(chan HashMetadata),
+		HashDataCh:     make(chan HashEntry),
+	}
+	p := &parser{h: newChannelHandler(ctx)}
+
+	go readAndNotify(t, &buffer, "hashmap", p.readHashMapInListpack)
+
+	var entries []HashEntry
+	stop := false
+	for !stop {
+		select {
+		case md := <-ctx.HashMetadataCh:
+			equals(t, "hashmap", DataToString(md.Key))
+			equals(t, int64(2), md.Len)
+		case d := <-ctx.HashDataCh:
+			entries = append(entries, d)
+		case <-end:
+			stop = true
+		}
+	}
+
+	equals(t, 2, len(entries))
+	equals(t, "foo", DataToString(entries[0].Key))
+	equals(t, "bar", DataToString(entries[0].Value))
+	equals(t, "baz", DataToString(entries[1].Key))
+	equals(t, int64(1), entries[1].Value)
+}
+
+func TestReadHashMapInListpackNoData(t *testing.T) {
+	var buffer bytes.Buffer
+
+	p := &parser{}
+	err := p.readHashMapInListpack(KeyObject{Key: []byte("hashmap")}, bufio.NewReader(&buffer))
+	equals(t, io.EOF, err)
+}
+
+func TestReadHashMapInListpackEmptyListpack(t *testing.T) {
+	var buffer bytes.Buffer
+
+	br := bufio.NewWriter(&buffer)
+	br.WriteByte(1)
+	br.WriteByte(0)
+	br.Flush()
+
+	p := &parser{}
+	err := p.readHashMapInListpack(KeyObject{Key: []byte("hashmap")}, bufio.NewReader(&buffer))
+	equals(t, "unexpected EOF", err.Error())
+}
+
+func TestHashEntryString(t *testing.T) {
+	e := HashEntry{Key: "foo", Value: "bar"}
+	equals(t, "HashEntry{Key: foo, Value: bar}", e.String())
+
+	e.ExpiryTime = millisecondsToTime(1700000000000)
+	equals(t, "HashEntry{Key: foo, Value: bar, ExpiryTime: 2023-11-14 22:13:20 +0000 UTC}", e.String())
+}
+
+// Read a hash with the parser method f and return what was sent
+func readTestHashEntries(t *testing.T, buffer *bytes.Buffer, f func(p *parser, key KeyObject, r io.Reader) error) (HashMetadata, []HashEntry) {
+	ctx := ParserContext{
+		HashMetadataCh: make(chan HashMetadata),
+		HashDataCh:     make(chan HashEntry),
+	}
+	p := &parser{h: newChannelHandler(ctx)}
+
+	go readAndNotify(t, buffer, "hashmap", func(key KeyObject, r io.Reader) error {
+		return f(p, key, r)
+	})
+
+	var md HashMetadata
+	var entries []HashEntry
+	stop := false
+	for !stop {
+		select {
+		case v := <-ctx.HashMetadataCh:
+			md = v
+		case d := <-ctx.HashDataCh:
+			entries = append(entries, d)
+		case <-end:
+			stop = true
+		}
+	}
+
+	return md, entries
+}
+
+const testHashMinExpire = 1700000000000
+
+func TestReadHashMapWithMetadata(t *testing.T) {
+	var buffer bytes.Buffer
+	br := bufio.NewWriter(&buffer)
+
+	binary.Write(br, binary.LittleEndian, int64(testHashMinExpire)) // minimum expiry time
+	writeLen(br, 2)                                                 // hashmap len
+	writeLen(br, 1)                                                 // ttl, relative to the minimum expiry time
+	writeString(br, "foo")
+	writeString(br, "bar")
+	writeLen(br, 0) // no ttl
+	writeString(br, "baz")
+	writeString(br, "qux")
+	br.Flush()
+
+	md, entries := readTestHashEntries(t, &buffer, func(p *parser, key KeyObject, r io.Reader) error {
+		return p.readHashMapWithMetadata(key, r, false)
+	})
+
+	equals(t, "hashmap", DataToString(md.Key))
+	equals(t, int64(2), md.Len)
+	equals(t, 2, len(entries))
+	equals(t, "foo", DataToString(entries[0].Key))
+	equals(t, "bar", DataToString(entries[0].Value))
+	equals(t, millisecondsToTime(testHashMinExpire), entries[0].ExpiryTime)
+	equals(t, "baz", DataToString(entries[1].Key))
+	equals(t, "qux", DataToString(entries[1].Value))
+	equals(t, true, entries[1].ExpiryTime.IsZero())
+}
+
+func TestReadHashMapWithMetadataPreGA(t *testing.T) {
+	var buffer bytes.Buffer
+	br := bufio.NewWriter(&buffer)
+
+	writeLen(br, 1)                     // hashmap len
+	writeLen(br, testHashMinExpire+500) // absolute ttl
+	writeString(br, "foo")
+	writeString(br, "bar")
+	br.Flush()
+
+	md, entries := readTestHashEntries(t, &buffer, func(p *parser, key KeyObject, r io.Reader) error {
+		return p.readHashMapWithMetadata(key, r, true)
+	})
+
+	equals(t, int64(1), md.Len)
+	equals(t, 1, len(entries))
+	equals(t, millisecondsToTime(testHashMinExpire+500), entries[0].ExpiryTime)
+}
+
+func TestReadHashMapWithMetadataNoMinExpire(t *testing.T) {
+	var buffer bytes.Buffer
+	buffer.Write([]byte{1, 2, 3})
+
+	p := &parser{}
+	err := p.readHashMapWithMetadata(KeyObject{Key: []byte("hashmap")}, bufio.NewReader(&buffer), false)
+	equals(t, io.ErrUnexpectedEOF, err)
+}
+
+func TestReadHashMapWithMetadataEncodedLen(t *testing.T) {
+	var buffer bytes.Buffer
+	buffer.WriteByte(0xC0)
+
+	p := &parser{}
+	err := p.readHashMapWithMetadata(KeyObject{Key: []byte("hashmap")}, bufio.NewReader(&buffer), true)
+	equals(t, ErrUnexpectedEncodedLength, err)
+}
+
+func TestReadHashMapWithMetadataEncodedTTL(t *testing.T) {
+	var buffer bytes.Buffer
+	buffer.Write([]byte{1, 0xC0})
+
+	p := &parser{h: NopHandler{}}
+	err := p.readHashMapWithMetadata(KeyObject{Key: []byte("hashmap")}, bufio.NewReader(&buffer), true)
+	equals(t, ErrUnexpectedEncodedLength, err)
+}
+
+func TestReadHashMapWithMetadataNoEntryValue(t *testing.T) {
+	var buffer bytes.Buffer
+	br := bufio.NewWriter(&buffer)
+
+	writeLen(br, 1)
+	writeLen(br, 0)
+	writeString(br, "foo")
+	br.Flush()
+
+	p := &parser{h: NopHandler{}}
+	err := p.readHashMapWithMetadata(KeyObject{Key: []byte("hashmap")}, bufio.NewReader(&buffer), true)
+	equals(t, io.EOF, err)
+}
+
+func TestReadHashMapInListpackEx(t *testing.T) {
+	var buffer bytes.Buffer
+	br := bufio.NewWriter(&buffer)
+
+	binary.Write(br, binary.LittleEndian, int64(testHashMinExpire)) // minimum expiry time
+	writeString(br, string(newListpack("foo", "bar", int64(testHashMinExpire), "baz", int64(1), int64(0))))
+	br.Flush()
+
+	md, entries := readTestHashEntries(t, &buffer, func(p *parser, key KeyObject, r io.Reader) error {
+		return p.readHashMapInListpackEx(key, r, false)
+	})
+
+	equals(t, "hashmap", DataToString(md.Key))
+	equals(t, int64(2), md.Len)
+	equals(t, 2, len(entries))
+	equals(t, "foo", DataToString(entries[0].Key))
+	equals(t, "bar", DataToString(entries[0].Value))
+	equals(t, millisecondsToTime(testHashMinExpire), entries[0].ExpiryTime)
+	equals(t, "baz", DataToString(entries[1].Key))
+	equals(t, int64(1), entries[1].Value)
+	equals(t, true, entries[1].ExpiryTime.IsZero())
+}
+
+func TestReadHashMapInListpackExPreGA(t *testing.T) {
+	var buffer bytes.Buffer
+	br := bufio.NewWriter(&buffer)
+
+	writeString(br, string(newListpack("foo", "bar", int64(testHashMinExpire))))
+	br.Flush()
+
+	md, entries := readTestHashEntries(t, &buffer, func(p *parser, key KeyObject, r io.Reader) error {
+		return p.readHashMapInListpackEx(key, r, true)
+	})
+
+	equals(t, int64(1), md.Len)
+	equals(t, 1, len(entries))
+	equals(t, millisecondsToTime(testHashMinExpire), entries[0].ExpiryTime)
+}
+
+func TestReadHashMapInListpackExInvalidTTL(t *testing.T) {
+	var buffer bytes.Buffer
+	br := bufio.NewWriter(&buffer)
+
+	writeString(br, string(newListpack("foo", "bar", "baz")))
+	br.Flush()
+
+	p := &parser{h: NopHandler{}}
+	err := p.readHashMapInListpackEx(KeyObject{Key: []byte("hashmap")}, bufio.NewReader(&buffer), true)
+	equals(t, ErrUnexpectedListpackEncoding, err)
+}
+
+func TestReadHashMapInListpackExNoData(t *testing.T) {
+	var buffer bytes.Buffer
+
+	p := &parser{}
+	err := p.readHashMapInListpackEx(KeyObject{Key: []byte("hashmap")}, bufio.NewReader(&buffer), false)
+	equals(t, io.EOF, err)
+}
+
 func TestReadZipMap(t *testing.T) {
 	var buffer bytes.Buffer
 
@@ -190,7 +436,7 @@ func TestReadZipMap(t *testing.T) {
 		HashMetadataCh: make(chan HashMetadata),
 		HashDataCh:     make(chan HashEntry),
 	}
-	p := &parser{ctx: ctx}
+	p := &parser{h: newChannelHandler(ctx)}
 
 	go readAndNotify(t, &buffer, "hashmap", p.readZipMap)
 
@@ -229,7 +475,7 @@ func TestReadZipMapBigKey(t *testing.T) {
 		HashMetadataCh: make(chan HashMetadata),
 		HashDataCh:     make(chan HashEntry),
 	}
-	p := &parser{ctx: ctx}
+	p := &parser{h: newChannelHandler(ctx)}
 
 	go readAndNotify(t, &buffer, "hashmap", p.readZipMap)
 
@@ -267,7 +513,7 @@ func TestReadZipMapBigMapLen(t *testing.T) {
 		HashMetadataCh: make(chan HashMetadata),
 		HashDataCh:     make(chan HashEntry),
 	}
-	p := &parser{ctx: ctx}
+	p := &parser{h: newChannelHandler(ctx)}
 
 	go readAndNotify(t, &buffer, "hashmap", p.readZipMap)
 
@@ -306,7 +552,7 @@ func TestReadZipMapSkipFreeBytes(t *testing.T) {
 		HashMetadataCh: make(chan HashMetadata),
 		HashDataCh:     make(chan HashEntry),
 	}
-	p := &parser{ctx: ctx}
+	p := &parser{h: newChannelHandler(ctx)}
 
 	go readAndNotify(t, &buffer, "hashmap", p.readZipMap)
 
@@ -372,7 +618,7 @@ func TestReadZipMapFailEntryKeyLength(t *testing.T) {
 	br.Flush()
 
 	ctx := ParserContext{HashMetadataCh: make(chan HashMetadata)}
-	p := &parser{ctx: ctx}
+	p := &parser{h: newChannelHandler(ctx)}
 
 	go func() {
 		md := <-ctx.HashMetadataCh
@@ -395,7 +641,7 @@ func TestReadZipMapFailEntryKeyData(t *testing.T) {
 	br.Flush()
 
 	ctx := ParserContext{HashMetadataCh: make(chan HashMetadata)}
-	p := &parser{ctx: ctx}
+	p := &parser{h: newChannelHandler(ctx)}
 
 	go func() {
 		md := <-ctx.HashMetadataCh
@@ -419,7 +665,7 @@ func TestReadZipMapFailEntryValByte(t *testing.T) {
 	br.Flush()
 
 	ctx := ParserContext{HashMetadataCh: make(chan HashMetadata)}
-	p := &parser{ctx: ctx}
+	p := &parser{h: newChannelHandler(ctx)}
 
 	go func() {
 		md := <-ctx.HashMetadataCh
@@ -445,7 +691,7 @@ func TestReadZipMapFailEntryValLength(t *testing.T) {
 	br.Flush()
 
 	ctx := ParserContext{HashMetadataCh: make(chan HashMetadata)}
-	p := &parser{ctx: ctx}
+	p := &parser{h: newChannelHandler(ctx)}
 
 	go func() {
 		md := <-ctx.HashMetadataCh
@@ -470,7 +716,7 @@ func TestReadZipMapFailEntryFreeByte(t *testing.T) {
 	br.Flush()
 
 	ctx := ParserContext{HashMetadataCh: make(chan HashMetadata)}
-	p := &parser{ctx: ctx}
+	p := &parser{h: newChannelHandler(ctx)}
 
 	go func() {
 		md := <-ctx.HashMetadataCh
@@ -496,7 +742,7 @@ func TestReadZipMapFailEntryValData(t *testing.T) {
 	br.Flush()
 
 	ctx := ParserContext{HashMetadataCh: make(chan HashMetadata)}
-	p := &parser{ctx: ctx}
+	p := &parser{h: newChannelHandler(ctx)}
 
 	go func() {
 		md := <-ctx.HashMetadataCh
@@ -523,7 +769,7 @@ func TestReadZipMapFailSkipFreeBytes(t *testing.T) {
 	br.Flush()
 
 	ctx := ParserContext{HashMetadataCh: make(chan HashMetadata)}
-	p := &parser{ctx: ctx}
+	p := &parser{h: newChannelHandler(ctx)}
 
 	go func() {
 		md := <-ctx.HashMetadataCh
@@ -553,9 +799,12 @@ func TestReadZipMapFailLastReadByte(t *testing.T) {
 		HashMetadataCh: make(chan HashMetadata),
 		HashDataCh:     make(chan HashEntry),
 	}
-	p := &parser{ctx: ctx}
+	p := &parser{h: newChannelHandler(ctx)}
 
+	done := make(chan struct{})
 	go func() {
+		defer close(done)
+
 		stop := false
 		for !stop {
 			select {
@@ -574,4 +823,5 @@ func TestReadZipMapFailLastReadByte(t *testing.T) {
 	err := p.readZipMap(KeyObject{Key: []byte("hashmap")}, bufio.NewReader(&buffer))
 	equals(t, io.EOF, err)
 	end <- true
+	<-done
 }
@@ -0,0 +1,124 @@
+package rdbtools
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// The flavour of a RDB file, meaning the server which wrote it
+type Flavour int
+
+const (
+	FlavourRedis  Flavour = iota // The file starts with the REDIS magic string
+	FlavourValkey                // The file starts with the VALKEY magic string (Valkey >= 9.0)
+)
+
+// Returns the name of the flavour
+func (f Flavour) String() string {
+	switch f {
+	case FlavourRedis:
+		return "Redis"
+	case FlavourValkey:
+		return "Valkey"
+	default:
+		return fmt.Sprintf("Flavour(%d)", int(f))
+	}
+}
+
+// Represents the header of a RDB file.
+//
+// Note that Valkey < 9.0 writes files with the REDIS magic string, which are reported with FlavourRedis.
+type Header struct {
+	Flavour Flavour
+	Version int // The RDB version number
+}
+
+// Returns a visualization of the header
+func (h Header) String() string {
+	return fmt.Sprintf("Header{Flavour: %s, Version: %d}", h.Flavour, h.Version)
+}
+
+// The value type of the hashes with field expiration times of Valkey >= 9.0. Redis
+// uses the same number for the hashes with field expiration times of its release
+// candidates, with a different encoding.
+const valkeyHashWithFieldExpiryType = 22
+
+// Returns an error if values of type b can't be read in a file with this header.
+//
+// The Redis types following the Valkey hash with field expiration times don't exist in Valkey files.
+func (h Header) checkValueType(b byte) error {
+	if h.Flavour == FlavourValkey && b > valkeyHashWithFieldExpiryType {
+		return ErrUnknownValueType
+	}
+
+	return nil
+}
+
+func readHeader(r io.Reader) (Header, error) {
+	flavour, err := readMagicString(r)
+	if err != nil {
+		return Header{}, err
+	}
+
+	version, err := readVersionNumber(r, flavour)
+	if err != nil {
+		return Header{}, err
+	}
+
+	return Header{Flavour: flavour, Version: version}, nil
+}
+
+func readMagicString(r io.Reader) (Flavour, error) {
+	data := make([]byte, 5)
+	if _, err := io.ReadFull(r, data); err == io.ErrUnexpectedEOF {
+		return -1, ErrInvalidMagicString
+	} else if err != nil {
+		return -1, err
+	}
+
+	switch string(data) {
+	case "REDIS":
+		return FlavourRedis, nil
+	case "VALKE":
+		if _, err := io.ReadFull(r, data[:1]); err != nil {
+			return -1, err
+		}
+
+		if data[0] != 'Y' {
+			return -1, ErrInvalidMagicString
+		}
+
+		return FlavourValkey, nil
+	default:
+		return -1, ErrInvalidMagicString
+	}
+}
+
+// Read the version number, which has 4 digits with the Redis flavour
+// and 3 digits with the Valkey flavour, for a magic string and version of 9 bytes.
+func readVersionNumber(r io.Reader, flavour Flavour) (int, error) {
+	digits, min, max := 4, 1, RedisRdbVersion
+	if flavour == FlavourValkey {
+		digits, min, max = 3, ValkeyMinRdbVersion, ValkeyRdbVersion
+	}
+
+	data := make([]byte, digits)
+	if _, err := io.ReadFull(r, data); err == io.ErrUnexpectedEOF {
+		return -1, ErrInvalidRDBVersionNumber
+	} else if err != nil {
+		return -1, err
+	}
+
+	val := string(data)
+	ival, err := strconv.Atoi(val)
+	if err != nil {
+		return -1, err
+	}
+
+	if ival < min || ival > max {
+		return -1, ErrInvalidRDBVersionNumber
+	}
+
+	return ival, nil
+}
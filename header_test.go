@@ -0,0 +1,197 @@
+package rdbtools
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"testing"
+	"testing/iotest"
+)
+
+func TestReadMagicString(t *testing.T) {
+	var buffer bytes.Buffer
+
+	br := bufio.NewWriter(&buffer)
+	br.WriteString("REDIS")
+	br.Flush()
+
+	f, err := readMagicString(bufio.NewReader(&buffer))
+	ok(t, err)
+	equals(t, FlavourRedis, f)
+
+	// No data
+	buffer.Reset()
+
+	_, err = readMagicString(bufio.NewReader(&buffer))
+	equals(t, io.EOF, err)
+
+	// Not enough data
+	buffer.Reset()
+	br.WriteString("FOO")
+	br.Flush()
+
+	_, err = readMagicString(bufio.NewReader(&buffer))
+	equals(t, ErrInvalidMagicString, err)
+
+	// Invalid data
+	buffer.Reset()
+	br.WriteString("FOOBA")
+	br.Flush()
+
+	_, err = readMagicString(bufio.NewReader(&buffer))
+	equals(t, ErrInvalidMagicString, err)
+
+	// Valkey
+	buffer.Reset()
+	br.WriteString("VALKEY")
+	br.Flush()
+
+	f, err = readMagicString(bufio.NewReader(&buffer))
+	ok(t, err)
+	equals(t, FlavourValkey, f)
+
+	// Valkey - no data
+	buffer.Reset()
+	br.WriteString("VALKE")
+	br.Flush()
+
+	_, err = readMagicString(bufio.NewReader(&buffer))
+	equals(t, io.EOF, err)
+
+	// Valkey - invalid data
+	buffer.Reset()
+	br.WriteString("VALKEX")
+	br.Flush()
+
+	_, err = readMagicString(bufio.NewReader(&buffer))
+	equals(t, ErrInvalidMagicString, err)
+}
+
+func TestReadVersionNumber(t *testing.T) {
+	var buffer bytes.Buffer
+
+	br := bufio.NewWriter(&buffer)
+	br.WriteString("0006")
+	br.Flush()
+
+	v, err := readVersionNumber(bufio.NewReader(&buffer), FlavourRedis)
+	ok(t, err)
+	equals(t, 6, v)
+
+	// No data
+	buffer.Reset()
+
+	v, err = readVersionNumber(bufio.NewReader(&buffer), FlavourRedis)
+	equals(t, io.EOF, err)
+	equals(t, -1, v)
+
+	// Not enough data
+	buffer.Reset()
+	br.WriteString("FOO")
+	br.Flush()
+
+	v, err = readVersionNumber(bufio.NewReader(&buffer), FlavourRedis)
+	equals(t, ErrInvalidRDBVersionNumber, err)
+	equals(t, -1, v)
+
+	// Not a number
+	buffer.Reset()
+	br.WriteString("foob")
+	br.Flush()
+
+	v, err = readVersionNumber(bufio.NewReader(&buffer), FlavourRedis)
+	equals(t, "strconv.Atoi: parsing \"foob\": invalid syntax", err.Error())
+	equals(t, -1, v)
+
+	// Modern version number
+	buffer.Reset()
+	br.WriteString("0012")
+	br.Flush()
+
+	v, err = readVersionNumber(bufio.NewReader(&buffer), FlavourRedis)
+	ok(t, err)
+	equals(t, 12, v)
+
+	// Wrong version number
+	buffer.Reset()
+	br.WriteString("0013")
+	br.Flush()
+
+	v, err = readVersionNumber(bufio.NewReader(&buffer), FlavourRedis)
+	equals(t, ErrInvalidRDBVersionNumber, err)
+	equals(t, -1, v)
+}
+
+func TestReadVersionNumberValkey(t *testing.T) {
+	var buffer bytes.Buffer
+
+	br := bufio.NewWriter(&buffer)
+	br.WriteString("080")
+	br.Flush()
+
+	v, err := readVersionNumber(bufio.NewReader(&buffer), FlavourValkey)
+	ok(t, err)
+	equals(t, 80, v)
+
+	// Not enough data
+	buffer.Reset()
+	br.WriteString("08")
+	br.Flush()
+
+	v, err = readVersionNumber(bufio.NewReader(&buffer), FlavourValkey)
+	equals(t, ErrInvalidRDBVersionNumber, err)
+	equals(t, -1, v)
+
+	// Redis version number
+	buffer.Reset()
+	br.WriteString("011")
+	br.Flush()
+
+	v, err = readVersionNumber(bufio.NewReader(&buffer), FlavourValkey)
+	equals(t, ErrInvalidRDBVersionNumber, err)
+	equals(t, -1, v)
+
+	// Wrong version number
+	buffer.Reset()
+	br.WriteString("081")
+	br.Flush()
+
+	v, err = readVersionNumber(bufio.NewReader(&buffer), FlavourValkey)
+	equals(t, ErrInvalidRDBVersionNumber, err)
+	equals(t, -1, v)
+}
+
+func TestReadHeader(t *testing.T) {
+	h, err := readHeader(bytes.NewReader([]byte("REDIS0011")))
+	ok(t, err)
+	equals(t, Header{Flavour: FlavourRedis, Version: 11}, h)
+
+	h, err = readHeader(bytes.NewReader([]byte("VALKEY080")))
+	ok(t, err)
+	equals(t, Header{Flavour: FlavourValkey, Version: 80}, h)
+
+	_, err = readHeader(bytes.NewReader([]byte("VALKEY0011")))
+	equals(t, ErrInvalidRDBVersionNumber, err)
+
+	// Short reads
+	h, err = readHeader(iotest.OneByteReader(bytes.NewReader([]byte("VALKEY080"))))
+	ok(t, err)
+	equals(t, Header{Flavour: FlavourValkey, Version: 80}, h)
+}
+
+func TestHeaderString(t *testing.T) {
+	equals(t, "Header{Flavour: Valkey, Version: 80}", Header{Flavour: FlavourValkey, Version: 80}.String())
+	equals(t, "Header{Flavour: Redis, Version: 11}", Header{Flavour: FlavourRedis, Version: 11}.String())
+	equals(t, "Flavour(5)", Flavour(5).String())
+}
+
+func TestHeaderCheckValueType(t *testing.T) {
+	redis := Header{Flavour: FlavourRedis, Version: 12}
+	ok(t, redis.checkValueType(22))
+	ok(t, redis.checkValueType(25))
+
+	valkey := Header{Flavour: FlavourValkey, Version: 80}
+	ok(t, valkey.checkValueType(21))
+	ok(t, valkey.checkValueType(22))
+	equals(t, ErrUnknownValueType, valkey.checkValueType(23))
+}
@@ -9,23 +9,57 @@ import (
 type KeyObject struct {
 	ExpiryTime time.Time   // The expiry time of the key. If none, this object IsZero() method will return true
 	Key        interface{} // The key value
+	Slot       int         // The cluster hash slot of the key
+	DB         int         // The database of the key
+
+	// The time Expired evaluates the expiry time against. If zero, the current time is used.
+	// The parser sets it to the creation time of the RDB file with ParserContext.ExpireAtSnapshotTime or WithExpireAtSnapshotTime.
+	ExpiryReference time.Time
+
+	IdleSeconds    uint64 // The LRU idle time of the key in seconds, only set if HasIdleSeconds is true
+	HasIdleSeconds bool   // Whether the RDB contains the LRU idle time of the key (maxmemory-policy set to an LRU policy)
+	LFUCounter     uint8  // The logarithmic LFU access counter of the key, only set if HasLFUCounter is true
+	HasLFUCounter  bool   // Whether the RDB contains the LFU counter of the key (maxmemory-policy set to an LFU policy)
+
+	MVCCTimestamp uint64 // The MVCC timestamp of the key saved by KeyDB, 0 if none
 }
 
 // Create a new key. If expiryTime >= 0 it will be used.
 func NewKeyObject(key interface{}, expiryTime int64) KeyObject {
 	k := KeyObject{
-		Key: key,
+		Key:  key,
+		Slot: keyObjectSlot(key),
 	}
 	if expiryTime >= 0 {
-		k.ExpiryTime = time.Unix(expiryTime/1000, 0).UTC()
+		k.ExpiryTime = millisecondsToTime(expiryTime)
 	}
 
 	return k
 }
 
-// Returns true if the key is expired (meaning the key's expiry time is before now), false otherwise.
+// Returns true if the key is expired (meaning the key's expiry time is before its ExpiryReference, or now if not set), false otherwise.
 func (k KeyObject) Expired() bool {
-	return k.ExpiryTime.Before(time.Now())
+	if !k.ExpiryReference.IsZero() {
+		return k.ExpiredAt(k.ExpiryReference)
+	}
+
+	return k.ExpiredAt(time.Now())
+}
+
+// Returns true if the key has an expiry time which is before ref, false otherwise.
+// Like Redis, a key is not expired yet at its exact expiry time.
+func (k KeyObject) ExpiredAt(ref time.Time) bool {
+	return !k.ExpiryTime.IsZero() && k.ExpiryTime.Before(ref)
+}
+
+// Returns the time to live of the key at ref, which is negative if the key is expired at ref.
+// The boolean is false if the key has no expiry time.
+func (k KeyObject) TTLAt(ref time.Time) (time.Duration, bool) {
+	if k.ExpiryTime.IsZero() {
+		return 0, false
+	}
+
+	return k.ExpiryTime.Sub(ref), true
 }
 
 // Return a visualization of the key.
@@ -33,3 +33,56 @@ func TestNewKeyObjectExpired(t *testing.T) {
 	equals(t, false, k.ExpiryTime.IsZero())
 	equals(t, true, k.Expired())
 }
+
+// Without expiry time
+func TestNewKeyObjectNoExpiry(t *testing.T) {
+	k := NewKeyObject("test", -1)
+
+	equals(t, false, k.Expired())
+	equals(t, false, k.ExpiredAt(time.Now()))
+
+	_, ok := k.TTLAt(time.Now())
+	equals(t, false, ok)
+}
+
+// With a millisecond precise expiry time
+func TestNewKeyObjectMillisecondExpiry(t *testing.T) {
+	dt := time.Date(2100, time.January, 1, 0, 0, 0, 250*int(time.Millisecond), time.UTC)
+	k := NewKeyObject("test", dt.UnixNano()/int64(time.Millisecond))
+
+	equals(t, dt, k.ExpiryTime)
+	equals(t, "KeyObject{ExpiryTime: 2100-01-01 00:00:00.25 +0000 UTC, Key: test}", k.String())
+}
+
+func TestKeyObjectExpiredAt(t *testing.T) {
+	dt := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	k := NewKeyObject("test", dt.Unix()*1000)
+
+	equals(t, false, k.ExpiredAt(dt.Add(-time.Millisecond)))
+	equals(t, false, k.ExpiredAt(dt))
+	equals(t, true, k.ExpiredAt(dt.Add(time.Millisecond)))
+	equals(t, true, k.ExpiredAt(dt.Add(time.Hour)))
+}
+
+func TestKeyObjectTTLAt(t *testing.T) {
+	dt := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	k := NewKeyObject("test", dt.Unix()*1000)
+
+	ttl, ok := k.TTLAt(dt.Add(-time.Minute))
+	equals(t, true, ok)
+	equals(t, time.Minute, ttl)
+
+	ttl, ok = k.TTLAt(dt.Add(time.Second))
+	equals(t, true, ok)
+	equals(t, -time.Second, ttl)
+}
+
+// With an expiry reference, the key is evaluated against it instead of now
+func TestKeyObjectExpiryReference(t *testing.T) {
+	dt := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	k := NewKeyObject("test", dt.Unix()*1000)
+	equals(t, true, k.Expired())
+
+	k.ExpiryReference = dt.Add(-time.Hour)
+	equals(t, false, k.Expired())
+}
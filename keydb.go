@@ -0,0 +1,225 @@
+package rdbtools
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Represents the expiry time of a member of a set, hash or sorted set, as saved by KeyDB (EXPIREMEMBER).
+//
+// Member expiry times follow their collection in the file, so they are sent after it.
+// With WithKeyDBMemberExpiries, the expiry times of hash fields and sorted set members are
+// set on their HashEntry and SortedSetEntry instead, and those of set members are sent right after the member.
+type MemberExpiry struct {
+	Key        KeyObject
+	Member     interface{}
+	ExpiryTime time.Time
+}
+
+// Returns a visualization of the member expiry
+func (m MemberExpiry) String() string {
+	return fmt.Sprintf("MemberExpiry{Key: %s, Member: %s, ExpiryTime: %s}", DataToString(m.Key), DataToString(m.Member), m.ExpiryTime)
+}
+
+// Handle the auxiliary fields KeyDB uses to extend the format:
+//
+//   - "mvcc-tstamp" precedes a key and holds its MVCC timestamp
+//   - "keydb-subexpire-key" and "keydb-subexpire-when" follow a key and hold the expiry time of one of its members
+func (p *parser) readKeyDBAuxField(key string, value interface{}) error {
+	switch key {
+	case "mvcc-tstamp":
+		if ts, err := strconv.ParseUint(DataToString(value), 10, 64); err == nil {
+			p.mvccTimestamp = ts
+		}
+	case "keydb-subexpire-key":
+		p.subexpireMember = value
+	case "keydb-subexpire-when":
+		member := p.subexpireMember
+		p.subexpireMember = nil
+
+		// Like KeyDB, skip the entry if it is not tied to a key and a member
+		if p.lastKey == nil || member == nil {
+			return nil
+		}
+
+		when, err := strconv.ParseInt(DataToString(value), 10, 64)
+		if err != nil {
+			return nil
+		}
+
+		return p.h.MemberExpiry(MemberExpiry{Key: *p.lastKey, Member: member, ExpiryTime: millisecondsToTime(when)})
+	}
+
+	return nil
+}
+
+// Whether an auxiliary field holds the expiry time of a member of the last key
+func isKeyDBSubexpireField(key string) bool {
+	return key == "keydb-subexpire-key" || key == "keydb-subexpire-when"
+}
+
+// Read a key value pair like readKeyValuePair, holding the events of a set, hash or
+// sorted set in p.pending.
+//
+// KeyDB saves the member expiry times after the value of their key, so the entries
+// are kept until the next opcode which isn't a member expiry time, when flushPending
+// sends them with their expiry times.
+func (p *parser) readBufferedKeyValuePair(r io.Reader) error {
+	key, b, err := p.readKey(r)
+	if err != nil {
+		return err
+	}
+
+	buffer := &memberExpiryBuffer{Handler: p.h, expiries: make(map[string]time.Time)}
+
+	p.h = buffer
+	err = p.readValue(key, b, r)
+	if err != nil || len(buffer.events) == 0 {
+		p.h = buffer.Handler
+		return err
+	}
+
+	p.pending = buffer
+	return nil
+}
+
+// Send the events held by readBufferedKeyValuePair
+func (p *parser) flushPending() error {
+	if p.pending == nil {
+		return nil
+	}
+
+	buffer := p.pending
+	p.h, p.pending = buffer.Handler, nil
+
+	return buffer.flush()
+}
+
+// A set member held by a memberExpiryBuffer, the members being of any type
+type bufferedSetMember struct {
+	member interface{}
+}
+
+// The end of a collection held by a memberExpiryBuffer
+type bufferedEnd struct {
+	key KeyObject
+	typ string // set, hash or zset
+}
+
+// A handler holding the events of a set, hash or sorted set and the member expiry times
+// which follow it, the other events being sent to the wrapped handler directly
+type memberExpiryBuffer struct {
+	Handler
+
+	events   []interface{}        // The metadata, entries and end of the collection, then the auxiliary fields
+	key      KeyObject            // The key of the collection
+	expiries map[string]time.Time // The expiry times by member
+}
+
+func (b *memberExpiryBuffer) StartSet(md SetMetadata) error {
+	b.key = md.Key
+	b.events = append(b.events, md)
+	return nil
+}
+
+func (b *memberExpiryBuffer) SetMember(e interface{}) error {
+	b.events = append(b.events, bufferedSetMember{e})
+	return nil
+}
+
+func (b *memberExpiryBuffer) EndSet(key KeyObject) error {
+	b.events = append(b.events, bufferedEnd{key, "set"})
+	return nil
+}
+
+func (b *memberExpiryBuffer) StartHash(md HashMetadata) error {
+	b.key = md.Key
+	b.events = append(b.events, md)
+	return nil
+}
+
+func (b *memberExpiryBuffer) HashEntry(e HashEntry) error {
+	b.events = append(b.events, e)
+	return nil
+}
+
+func (b *memberExpiryBuffer) EndHash(key KeyObject) error {
+	b.events = append(b.events, bufferedEnd{key, "hash"})
+	return nil
+}
+
+func (b *memberExpiryBuffer) StartSortedSet(md SortedSetMetadata) error {
+	b.key = md.Key
+	b.events = append(b.events, md)
+	return nil
+}
+
+func (b *memberExpiryBuffer) SortedSetEntry(e SortedSetEntry) error {
+	b.events = append(b.events, e)
+	return nil
+}
+
+func (b *memberExpiryBuffer) EndSortedSet(key KeyObject) error {
+	b.events = append(b.events, bufferedEnd{key, "zset"})
+	return nil
+}
+
+func (b *memberExpiryBuffer) AuxField(f AuxField) error {
+	b.events = append(b.events, f)
+	return nil
+}
+
+func (b *memberExpiryBuffer) MemberExpiry(m MemberExpiry) error {
+	b.expiries[DataToString(m.Member)] = m.ExpiryTime
+	return nil
+}
+
+// Send the events to the wrapped handler, with the expiry times of their members
+func (b *memberExpiryBuffer) flush() error {
+	h := b.Handler
+
+	for _, e := range b.events {
+		var err error
+		switch e := e.(type) {
+		case SetMetadata:
+			err = h.StartSet(e)
+		case bufferedSetMember:
+			err = h.SetMember(e.member)
+			if t, ok := b.expiries[DataToString(e.member)]; ok && err == nil {
+				err = h.MemberExpiry(MemberExpiry{Key: b.key, Member: e.member, ExpiryTime: t})
+			}
+		case HashMetadata:
+			err = h.StartHash(e)
+		case HashEntry:
+			if t, ok := b.expiries[DataToString(e.Key)]; ok {
+				e.ExpiryTime = t
+			}
+			err = h.HashEntry(e)
+		case SortedSetMetadata:
+			err = h.StartSortedSet(e)
+		case SortedSetEntry:
+			if t, ok := b.expiries[DataToString(e.Value)]; ok {
+				e.ExpiryTime = t
+			}
+			err = h.SortedSetEntry(e)
+		case bufferedEnd:
+			switch e.typ {
+			case "set":
+				err = h.EndSet(e.key)
+			case "hash":
+				err = h.EndHash(e.key)
+			case "zset":
+				err = h.EndSortedSet(e.key)
+			}
+		case AuxField:
+			err = h.AuxField(e)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
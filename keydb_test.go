@@ -0,0 +1,267 @@
+package rdbtools
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestMemberExpiryString(t *testing.T) {
+	m := MemberExpiry{Key: KeyObject{Key: "set"}, Member: "a", ExpiryTime: millisecondsToTime(1700000000000)}
+	equals(t, "MemberExpiry{Key: set, Member: a, ExpiryTime: 2023-11-14 22:13:20 +0000 UTC}", m.String())
+}
+
+// Write an auxiliary field
+func writeAuxField(w io.Writer, key, value string) {
+	w.Write([]byte{0xFA})
+	writeString(w, key)
+	writeString(w, value)
+}
+
+func TestReadKeyValuePairKeyDBExtensions(t *testing.T) {
+	var buffer bytes.Buffer
+
+	br := bufio.NewWriter(&buffer)
+	writeAuxField(br, "mvcc-tstamp", "1234")
+	br.WriteByte(2) // set
+	writeString(br, "set")
+	writeLen(br, 2)
+	writeString(br, "a")
+	writeString(br, "b")
+	writeAuxField(br, "keydb-subexpire-key", "a")
+	writeAuxField(br, "keydb-subexpire-when", "1700000000500")
+	br.WriteByte(0) // string
+	writeString(br, "foo")
+	writeString(br, "bar")
+	br.Flush()
+
+	ctx := ParserContext{
+		SetMetadataCh:  make(chan SetMetadata),
+		SetDataCh:      make(chan interface{}),
+		StringObjectCh: make(chan StringObject),
+		MemberExpiryCh: make(chan MemberExpiry),
+	}
+	p := &parser{h: newChannelHandler(ctx)}
+
+	done := make(chan bool)
+	go func() {
+		md := <-ctx.SetMetadataCh
+		equals(t, "set", DataToString(md.Key))
+		equals(t, uint64(1234), md.Key.MVCCTimestamp)
+		equals(t, "a", DataToString(<-ctx.SetDataCh))
+
+		// The expiry time of a set member follows it
+		m := <-ctx.MemberExpiryCh
+		equals(t, "set", DataToString(m.Key))
+		equals(t, "a", DataToString(m.Member))
+		equals(t, time.Date(2023, time.November, 14, 22, 13, 20, 500*int(time.Millisecond), time.UTC), m.ExpiryTime)
+
+		equals(t, "b", DataToString(<-ctx.SetDataCh))
+
+		s := <-ctx.StringObjectCh
+		equals(t, "foo", DataToString(s.Key))
+		equals(t, uint64(0), s.Key.MVCCTimestamp)
+		done <- true
+	}()
+
+	r := bufio.NewReader(&buffer)
+	for i := 0; i < 2; i++ {
+		err := p.readBufferedKeyValuePair(r)
+		ok(t, err)
+	}
+	<-done
+}
+
+func TestReadKeyDBMemberExpiryEntries(t *testing.T) {
+	var buffer bytes.Buffer
+
+	br := bufio.NewWriter(&buffer)
+	br.WriteByte(4) // hash
+	writeString(br, "hash")
+	writeLen(br, 2)
+	writeString(br, "f1")
+	writeString(br, "v1")
+	writeString(br, "f2")
+	writeString(br, "v2")
+	writeAuxField(br, "keydb-subexpire-key", "f2")
+	writeAuxField(br, "keydb-subexpire-when", "1700000000500")
+	br.WriteByte(3) // sorted set
+	writeString(br, "zset")
+	writeLen(br, 1)
+	writeString(br, "m")
+	writeString(br, "1")
+	writeAuxField(br, "keydb-subexpire-key", "m")
+	writeAuxField(br, "keydb-subexpire-when", "1700000000500")
+	writeAuxField(br, "foo", "bar")
+	br.WriteByte(0xFF)
+	br.Flush()
+
+	h := &recordingHandler{}
+	p := &parser{h: h}
+
+	r := bufio.NewReader(&buffer)
+	for i := 0; i < 2; i++ {
+		err := p.readBufferedKeyValuePair(r)
+		ok(t, err)
+	}
+	err := p.readBufferedKeyValuePair(r)
+	equals(t, errNoMoreKeyValuePair, err)
+
+	// The auxiliary fields are sent in the order of the file, after the entries
+	equals(t, []string{
+		"StartHash hash",
+		"HashEntry{Key: f1, Value: v1}",
+		"HashEntry{Key: f2, Value: v2, ExpiryTime: 2023-11-14 22:13:20.5 +0000 UTC}",
+		"EndHash hash",
+		"AuxField{Key: keydb-subexpire-key, Value: f2}",
+		"AuxField{Key: keydb-subexpire-when, Value: 1700000000500}",
+		"StartSortedSet zset",
+		"SortedSetEntry{Value: m, Score: 1.0000, ExpiryTime: 2023-11-14 22:13:20.5 +0000 UTC}",
+		"EndSortedSet zset",
+		"AuxField{Key: keydb-subexpire-key, Value: m}",
+		"AuxField{Key: keydb-subexpire-when, Value: 1700000000500}",
+		"AuxField{Key: foo, Value: bar}",
+	}, h.calls)
+}
+
+func TestReadKeyDBSubexpireWithoutKey(t *testing.T) {
+	var buffer bytes.Buffer
+
+	br := bufio.NewWriter(&buffer)
+	writeAuxField(br, "keydb-subexpire-key", "a")
+	writeAuxField(br, "keydb-subexpire-when", "1700000000500")
+	br.WriteByte(0xFF)
+	br.Flush()
+
+	// Sending on the channel would block
+	ctx := ParserContext{MemberExpiryCh: make(chan MemberExpiry)}
+	p := &parser{h: newChannelHandler(ctx)}
+
+	err := p.readKeyValuePair(bufio.NewReader(&buffer))
+	equals(t, errNoMoreKeyValuePair, err)
+}
+
+func TestReadKeyDBSubexpireWithoutMember(t *testing.T) {
+	var buffer bytes.Buffer
+
+	br := bufio.NewWriter(&buffer)
+	writeAuxField(br, "keydb-subexpire-when", "1700000000500")
+	br.WriteByte(0xFF)
+	br.Flush()
+
+	ctx := ParserContext{MemberExpiryCh: make(chan MemberExpiry)}
+	p := &parser{h: newChannelHandler(ctx), lastKey: &KeyObject{Key: []byte("set")}}
+
+	err := p.readKeyValuePair(bufio.NewReader(&buffer))
+	equals(t, errNoMoreKeyValuePair, err)
+}
+
+// Write a RDB file with a set whose member "a" has a KeyDB expiry time, followed by a string
+func writeKeyDBMemberExpiryRDB(buffer *bytes.Buffer) {
+	br := bufio.NewWriter(buffer)
+	br.WriteString("REDIS0009")
+	br.WriteByte(0xFE) // next database byte
+	br.WriteByte(0)    // database number
+	br.WriteByte(2)    // set
+	writeString(br, "set")
+	writeLen(br, 2)
+	writeString(br, "a")
+	writeString(br, "b")
+	writeAuxField(br, "keydb-subexpire-key", "a")
+	writeAuxField(br, "keydb-subexpire-when", "1700000000500")
+	br.WriteByte(0) // string
+	writeString(br, "foo")
+	writeString(br, "bar")
+	br.WriteByte(0xFF) // end of file
+	br.Flush()
+	writeChecksum(buffer)
+}
+
+// By default, the entries are streamed and the member expiry times sent after their collection
+func TestParseKeyDBMemberExpiries(t *testing.T) {
+	var buffer bytes.Buffer
+	writeKeyDBMemberExpiryRDB(&buffer)
+
+	h := &recordingHandler{}
+	err := NewHandlerParser(h).Parse(&buffer)
+	ok(t, err)
+	equals(t, []string{
+		"StartRDB Redis 9",
+		"StartDatabase 0",
+		"StartSet set",
+		"SetMember a",
+		"SetMember b",
+		"EndSet set",
+		"AuxField{Key: keydb-subexpire-key, Value: a}",
+		"AuxField{Key: keydb-subexpire-when, Value: 1700000000500}",
+		"MemberExpiry{Key: set, Member: a, ExpiryTime: 2023-11-14 22:13:20.5 +0000 UTC}",
+		"String foo",
+		"EndDatabase 0",
+		"EndRDB",
+	}, h.calls)
+}
+
+func TestParseWithKeyDBMemberExpiries(t *testing.T) {
+	var buffer bytes.Buffer
+	writeKeyDBMemberExpiryRDB(&buffer)
+
+	h := &recordingHandler{}
+	err := NewHandlerParser(h, WithKeyDBMemberExpiries()).Parse(&buffer)
+	ok(t, err)
+	equals(t, []string{
+		"StartRDB Redis 9",
+		"StartDatabase 0",
+		"StartSet set",
+		"SetMember a",
+		"MemberExpiry{Key: set, Member: a, ExpiryTime: 2023-11-14 22:13:20.5 +0000 UTC}",
+		"SetMember b",
+		"EndSet set",
+		"AuxField{Key: keydb-subexpire-key, Value: a}",
+		"AuxField{Key: keydb-subexpire-when, Value: 1700000000500}",
+		"String foo",
+		"EndDatabase 0",
+		"EndRDB",
+	}, h.calls)
+}
+
+// The channels are closed and the error sent even if the file ends while a collection is held
+func TestParseWithKeyDBMemberExpiriesTruncated(t *testing.T) {
+	var buffer bytes.Buffer
+
+	br := bufio.NewWriter(&buffer)
+	br.WriteString("REDIS0009")
+	br.WriteByte(0xFE) // next database byte
+	br.WriteByte(0)    // database number
+	br.WriteByte(2)    // set
+	writeString(br, "set")
+	writeLen(br, 1)
+	writeString(br, "a")
+	br.Flush()
+
+	ctx := ParserContext{
+		SetMetadataCh:       make(chan SetMetadata),
+		SetDataCh:           make(chan interface{}),
+		ErrCh:               make(chan error, 1),
+		KeyDBMemberExpiries: true,
+	}
+	p := NewParser(ctx)
+
+	err := p.Parse(&buffer)
+	equals(t, io.EOF, err)
+
+	select {
+	case err := <-ctx.ErrCh:
+		equals(t, io.EOF, err)
+	default:
+		t.Fatal("expected an error on ErrCh")
+	}
+
+	_, ok := <-ctx.SetMetadataCh
+	equals(t, false, ok)
+	_, ok = <-ctx.SetDataCh
+	equals(t, false, ok)
+	_, ok = <-ctx.ErrCh
+	equals(t, false, ok)
+}
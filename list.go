@@ -3,6 +3,7 @@ package rdbtools
 import (
 	"bufio"
 	"bytes"
+	"encoding/binary"
 	"fmt"
 	"io"
 )
@@ -27,35 +28,43 @@ func (p *parser) readList(key KeyObject, r io.Reader) error {
 		return ErrUnexpectedEncodedLength
 	}
 
-	p.ctx.ListMetadataCh <- ListMetadata{Key: key, Len: l}
+	if err := p.h.StartList(ListMetadata{Key: key, Len: int64(l)}); err != nil {
+		return err
+	}
 
-	for i := int64(0); i < l; i++ {
+	for i := uint64(0); i < l; i++ {
 		value, err := p.readString(r)
 		if err != nil {
 			return err
 		}
 
-		p.ctx.ListDataCh <- value
+		if err := p.h.ListElement(value); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
 func (p *parser) readListInZipList(key KeyObject, r io.Reader) error {
-	data, err := p.readString(r)
+	data, err := p.readBytesString(r)
 	if err != nil {
 		return err
 	}
 
 	onLenCallback := func(length int64) error {
-		p.ctx.ListMetadataCh <- ListMetadata{Key: key, Len: length}
+		if err := p.h.StartList(ListMetadata{Key: key, Len: length}); err != nil {
+			return err
+		}
 		return nil
 	}
 	onElementCallback := func(e interface{}) error {
-		p.ctx.ListDataCh <- e
+		if err := p.h.ListElement(e); err != nil {
+			return err
+		}
 		return nil
 	}
-	dr := bufio.NewReader(bytes.NewReader(data.([]byte)))
+	dr := bufio.NewReader(bytes.NewReader(data))
 
 	if err := p.readZipList(dr, onLenCallback, onElementCallback); err != nil {
 		return err
@@ -63,3 +72,180 @@ func (p *parser) readListInZipList(key KeyObject, r io.Reader) error {
 
 	return nil
 }
+
+// Read a list encoded as a quicklist (Redis >= 3.2), which is a sequence of zip lists.
+func (p *parser) readListInQuickList(key KeyObject, r io.Reader) error {
+	l, e, err := p.readLen(r)
+	if err != nil {
+		return err
+	}
+	if e {
+		return ErrUnexpectedEncodedLength
+	}
+
+	// The length of the list is only known after reading all the zip lists,
+	// so we keep them to add up their lengths before sending their elements.
+	nodes := make([][]byte, 0)
+	var length int64
+	for i := uint64(0); i < l; i++ {
+		data, err := p.readBytesString(r)
+		if err != nil {
+			return err
+		}
+
+		n, err := p.zipListLen(data)
+		if err != nil {
+			return err
+		}
+
+		nodes = append(nodes, data)
+		length += n
+	}
+
+	if err := p.h.StartList(ListMetadata{Key: key, Len: length}); err != nil {
+		return err
+	}
+
+	onLenCallback := func(length int64) error {
+		return nil
+	}
+	onElementCallback := func(e interface{}) error {
+		return p.h.ListElement(e)
+	}
+	for _, node := range nodes {
+		if err := p.readZipList(bytes.NewReader(node), onLenCallback, onElementCallback); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Read a list encoded as a quicklist version 2 (Redis >= 7.0).
+// Each node is either a single element (plain node) or a listpack of elements (packed node).
+func (p *parser) readListInQuickList2(key KeyObject, r io.Reader) error {
+	l, e, err := p.readLen(r)
+	if err != nil {
+		return err
+	}
+	if e {
+		return ErrUnexpectedEncodedLength
+	}
+
+	// Same as readListInQuickList, we keep the nodes to add up their lengths.
+	type quickListNode struct {
+		container uint64
+		element   interface{} // The element of a plain node
+		listpack  []byte      // The listpack of a packed node
+	}
+	nodes := make([]quickListNode, 0)
+	var length int64
+	for i := uint64(0); i < l; i++ {
+		container, e, err := p.readLen(r)
+		if err != nil {
+			return err
+		}
+		if e {
+			return ErrUnexpectedEncodedLength
+		}
+
+		data, err := p.readString(r)
+		if err != nil {
+			return err
+		}
+
+		switch container {
+		case 1: // Plain node
+			nodes = append(nodes, quickListNode{container: container, element: data})
+			length++
+		case 2: // Packed node
+			b, ok := data.([]byte)
+			if !ok {
+				return ErrUnexpectedEncodedLength
+			}
+
+			n, err := p.listpackLen(b)
+			if err != nil {
+				return err
+			}
+
+			nodes = append(nodes, quickListNode{container: container, listpack: b})
+			length += n
+		default:
+			return ErrUnexpectedQuickListContainer
+		}
+	}
+
+	if err := p.h.StartList(ListMetadata{Key: key, Len: length}); err != nil {
+		return err
+	}
+
+	onLenCallback := func(length int64) error {
+		return nil
+	}
+	onElementCallback := func(e interface{}) error {
+		return p.h.ListElement(e)
+	}
+	for _, node := range nodes {
+		if node.container == 1 {
+			if err := p.h.ListElement(node.element); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := p.readListpack(bytes.NewReader(node.listpack), onLenCallback, onElementCallback); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Returns the number of elements of a zip list.
+// It's only decoded when its header doesn't have room for the length.
+func (p *parser) zipListLen(data []byte) (int64, error) {
+	if len(data) >= 10 {
+		if n := binary.LittleEndian.Uint16(data[8:10]); n != 65535 {
+			return int64(n), nil
+		}
+	}
+
+	var length int64
+	onLenCallback := func(l int64) error {
+		length = l
+		return nil
+	}
+	onElementCallback := func(e interface{}) error {
+		return nil
+	}
+	if err := p.readZipList(bytes.NewReader(data), onLenCallback, onElementCallback); err != nil {
+		return 0, err
+	}
+
+	return length, nil
+}
+
+// Returns the number of elements of a listpack.
+// It's only decoded when its header doesn't have room for the length.
+func (p *parser) listpackLen(data []byte) (int64, error) {
+	if len(data) >= 6 {
+		if n := binary.LittleEndian.Uint16(data[4:6]); n != 65535 {
+			return int64(n), nil
+		}
+	}
+
+	var length int64
+	onLenCallback := func(l int64) error {
+		length = l
+		return nil
+	}
+	onElementCallback := func(e interface{}) error {
+		return nil
+	}
+	if err := p.readListpack(bytes.NewReader(data), onLenCallback, onElementCallback); err != nil {
+		return 0, err
+	}
+
+	return length, nil
+}
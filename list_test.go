@@ -3,6 +3,7 @@ package rdbtools
 import (
 	"bufio"
 	"bytes"
+	"encoding/binary"
 	"io"
 	"testing"
 )
@@ -26,17 +27,17 @@ func TestReadList(t *testing.T) {
 		ListMetadataCh: make(chan ListMetadata),
 		ListDataCh:     make(chan interface{}),
 	}
-	p := &parser{ctx: ctx}
+	p := &parser{h: newChannelHandler(ctx)}
 
 	go readAndNotify(t, &buffer, "list", p.readList)
 
 	stop := false
 	for !stop {
 		select {
-		case md := <-p.ctx.ListMetadataCh:
+		case md := <-ctx.ListMetadataCh:
 			equals(t, "list", DataToString(md.Key))
 			equals(t, int64(1), md.Len)
-		case d := <-p.ctx.ListDataCh:
+		case d := <-ctx.ListDataCh:
 			equals(t, "a", DataToString(d))
 		case <-end:
 			stop = true
@@ -74,7 +75,7 @@ func TestReadListNoElementData(t *testing.T) {
 	br.Flush()
 
 	ctx := ParserContext{ListMetadataCh: make(chan ListMetadata)}
-	p := &parser{ctx: ctx}
+	p := &parser{h: newChannelHandler(ctx)}
 
 	go func() {
 		md := <-ctx.ListMetadataCh
@@ -106,7 +107,7 @@ func TestReadListInZipList(t *testing.T) {
 		ListMetadataCh: make(chan ListMetadata),
 		ListDataCh:     make(chan interface{}),
 	}
-	p := &parser{ctx: ctx}
+	p := &parser{h: newChannelHandler(ctx)}
 
 	go readAndNotify(t, &buffer, "list", p.readListInZipList)
 
@@ -143,3 +144,304 @@ func TestReadListInZipListFail(t *testing.T) {
 	err := p.readListInZipList(KeyObject{Key: []byte("list")}, bufio.NewReader(&buffer))
 	equals(t, io.EOF, err)
 }
+
+func TestReadListInQuickList(t *testing.T) {
+	var buffer bytes.Buffer
+
+	br := bufio.NewWriter(&buffer)
+
+	br.WriteByte(2) // Number of zip lists
+
+	br.WriteByte(18)             // String length
+	br.Write([]byte{0, 0, 0, 0}) // zlBytes
+	br.Write([]byte{0, 0, 0, 0}) // zlTail
+	br.Write([]byte{2, 0})       // zlLen
+	br.WriteByte(0)              // len prev entry
+	br.WriteByte(3)              // Special flag
+	br.WriteString("foo")
+	br.WriteByte(5)    // len prev entry
+	br.WriteByte(0xFE) // int8
+	br.WriteByte(42)
+
+	br.WriteByte(15)             // String length
+	br.Write([]byte{0, 0, 0, 0}) // zlBytes
+	br.Write([]byte{0, 0, 0, 0}) // zlTail
+	br.Write([]byte{1, 0})       // zlLen
+	br.WriteByte(0)              // len prev entry
+	br.WriteByte(3)              // Special flag
+	br.WriteString("bar")
+
+	br.Flush()
+
+	ctx := ParserContext{
+		ListMetadataCh: make(chan ListMetadata),
+		ListDataCh:     make(chan interface{}),
+	}
+	p := &parser{h: newChannelHandler(ctx)}
+
+	go readAndNotify(t, &buffer, "list", p.readListInQuickList)
+
+	var elements []string
+	stop := false
+	for !stop {
+		select {
+		case md := <-ctx.ListMetadataCh:
+			equals(t, "list", DataToString(md.Key))
+			equals(t, int64(3), md.Len)
+			equals(t, 0, len(elements))
+		case d := <-ctx.ListDataCh:
+			elements = append(elements, DataToString(d))
+		case <-end:
+			stop = true
+		}
+	}
+
+	equals(t, []string{"foo", "42", "bar"}, elements)
+}
+
+func TestReadListInQuickListUnknownLength(t *testing.T) {
+	var buffer bytes.Buffer
+
+	br := bufio.NewWriter(&buffer)
+
+	br.WriteByte(2) // Number of zip lists
+
+	br.WriteByte(19)             // String length
+	br.Write([]byte{0, 0, 0, 0}) // zlBytes
+	br.Write([]byte{0, 0, 0, 0}) // zlTail
+	br.Write([]byte{0xFF, 0xFF}) // zlLen
+	br.WriteByte(0)              // len prev entry
+	br.WriteByte(3)              // Special flag
+	br.WriteString("foo")
+	br.WriteByte(5)    // len prev entry
+	br.WriteByte(0xFE) // int8
+	br.WriteByte(42)
+	br.WriteByte(0xFF) // End
+
+	br.WriteByte(15)             // String length
+	br.Write([]byte{0, 0, 0, 0}) // zlBytes
+	br.Write([]byte{0, 0, 0, 0}) // zlTail
+	br.Write([]byte{1, 0})       // zlLen
+	br.WriteByte(0)              // len prev entry
+	br.WriteByte(3)              // Special flag
+	br.WriteString("bar")
+
+	br.Flush()
+
+	ctx := ParserContext{
+		ListMetadataCh: make(chan ListMetadata),
+		ListDataCh:     make(chan interface{}),
+	}
+	p := &parser{h: newChannelHandler(ctx)}
+
+	go readAndNotify(t, &buffer, "list", p.readListInQuickList)
+
+	var elements []string
+	stop := false
+	for !stop {
+		select {
+		case md := <-ctx.ListMetadataCh:
+			equals(t, int64(3), md.Len)
+			equals(t, 0, len(elements))
+		case d := <-ctx.ListDataCh:
+			elements = append(elements, DataToString(d))
+		case <-end:
+			stop = true
+		}
+	}
+
+	equals(t, []string{"foo", "42", "bar"}, elements)
+}
+
+func TestReadListInQuickListNoData(t *testing.T) {
+	var buffer bytes.Buffer
+
+	p := &parser{}
+	err := p.readListInQuickList(KeyObject{Key: []byte("list")}, bufio.NewReader(&buffer))
+	equals(t, io.EOF, err)
+}
+
+func TestReadListInQuickListEncodedLen(t *testing.T) {
+	var buffer bytes.Buffer
+
+	br := bufio.NewWriter(&buffer)
+	br.WriteByte(0xC0)
+	br.Flush()
+
+	p := &parser{}
+	err := p.readListInQuickList(KeyObject{Key: []byte("list")}, bufio.NewReader(&buffer))
+	equals(t, ErrUnexpectedEncodedLength, err)
+}
+
+func TestReadListInQuickListNoZipList(t *testing.T) {
+	var buffer bytes.Buffer
+
+	br := bufio.NewWriter(&buffer)
+	br.WriteByte(1)
+	br.Flush()
+
+	p := &parser{}
+	err := p.readListInQuickList(KeyObject{Key: []byte("list")}, bufio.NewReader(&buffer))
+	equals(t, io.EOF, err)
+}
+
+func TestReadListInQuickListFail(t *testing.T) {
+	var buffer bytes.Buffer
+
+	br := bufio.NewWriter(&buffer)
+	br.WriteByte(1)
+	br.WriteByte(0)
+	br.Flush()
+
+	p := &parser{}
+	err := p.readListInQuickList(KeyObject{Key: []byte("list")}, bufio.NewReader(&buffer))
+	equals(t, io.EOF, err)
+}
+
+func TestReadListInQuickList2(t *testing.T) {
+	var buffer bytes.Buffer
+
+	br := bufio.NewWriter(&buffer)
+
+	br.WriteByte(2) // Number of nodes
+
+	lp := newListpack("foo", int64(42))
+	br.WriteByte(2) // Packed node
+	br.WriteByte(byte(len(lp)))
+	br.Write(lp)
+
+	br.WriteByte(1) // Plain node
+	br.WriteByte(3)
+	br.WriteString("bar")
+
+	br.Flush()
+
+	ctx := ParserContext{
+		ListMetadataCh: make(chan ListMetadata),
+		ListDataCh:     make(chan interface{}),
+	}
+	p := &parser{h: newChannelHandler(ctx)}
+
+	go readAndNotify(t, &buffer, "list", p.readListInQuickList2)
+
+	var elements []string
+	stop := false
+	for !stop {
+		select {
+		case md := <-ctx.ListMetadataCh:
+			equals(t, "list", DataToString(md.Key))
+			equals(t, int64(3), md.Len)
+			equals(t, 0, len(elements))
+		case d := <-ctx.ListDataCh:
+			elements = append(elements, DataToString(d))
+		case <-end:
+			stop = true
+		}
+	}
+
+	equals(t, []string{"foo", "42", "bar"}, elements)
+}
+
+func TestReadListInQuickList2UnknownLength(t *testing.T) {
+	var buffer bytes.Buffer
+
+	br := bufio.NewWriter(&buffer)
+
+	br.WriteByte(2) // Number of nodes
+
+	lp := newListpack("foo", int64(42))
+	binary.LittleEndian.PutUint16(lp[4:], 65535)
+	br.WriteByte(2) // Packed node
+	br.WriteByte(byte(len(lp)))
+	br.Write(lp)
+
+	br.WriteByte(1) // Plain node
+	br.WriteByte(3)
+	br.WriteString("bar")
+
+	br.Flush()
+
+	ctx := ParserContext{
+		ListMetadataCh: make(chan ListMetadata),
+		ListDataCh:     make(chan interface{}),
+	}
+	p := &parser{h: newChannelHandler(ctx)}
+
+	go readAndNotify(t, &buffer, "list", p.readListInQuickList2)
+
+	var elements []string
+	stop := false
+	for !stop {
+		select {
+		case md := <-ctx.ListMetadataCh:
+			equals(t, int64(3), md.Len)
+			equals(t, 0, len(elements))
+		case d := <-ctx.ListDataCh:
+			elements = append(elements, DataToString(d))
+		case <-end:
+			stop = true
+		}
+	}
+
+	equals(t, []string{"foo", "42", "bar"}, elements)
+}
+
+func TestReadListInQuickList2NoData(t *testing.T) {
+	var buffer bytes.Buffer
+
+	p := &parser{}
+	err := p.readListInQuickList2(KeyObject{Key: []byte("list")}, bufio.NewReader(&buffer))
+	equals(t, io.EOF, err)
+}
+
+func TestReadListInQuickList2EncodedLen(t *testing.T) {
+	var buffer bytes.Buffer
+
+	br := bufio.NewWriter(&buffer)
+	br.WriteByte(0xC0)
+	br.Flush()
+
+	p := &parser{}
+	err := p.readListInQuickList2(KeyObject{Key: []byte("list")}, bufio.NewReader(&buffer))
+	equals(t, ErrUnexpectedEncodedLength, err)
+}
+
+func TestReadListInQuickList2NoContainer(t *testing.T) {
+	var buffer bytes.Buffer
+
+	br := bufio.NewWriter(&buffer)
+	br.WriteByte(1)
+	br.Flush()
+
+	p := &parser{}
+	err := p.readListInQuickList2(KeyObject{Key: []byte("list")}, bufio.NewReader(&buffer))
+	equals(t, io.EOF, err)
+}
+
+func TestReadListInQuickList2NoNodeData(t *testing.T) {
+	var buffer bytes.Buffer
+
+	br := bufio.NewWriter(&buffer)
+	br.WriteByte(1)
+	br.WriteByte(2)
+	br.Flush()
+
+	p := &parser{}
+	err := p.readListInQuickList2(KeyObject{Key: []byte("list")}, bufio.NewReader(&buffer))
+	equals(t, io.EOF, err)
+}
+
+func TestReadListInQuickList2UnexpectedContainer(t *testing.T) {
+	var buffer bytes.Buffer
+
+	br := bufio.NewWriter(&buffer)
+	br.WriteByte(1)
+	br.WriteByte(3)
+	br.WriteByte(1)
+	br.WriteByte('a')
+	br.Flush()
+
+	p := &parser{}
+	err := p.readListInQuickList2(KeyObject{Key: []byte("list")}, bufio.NewReader(&buffer))
+	equals(t, ErrUnexpectedQuickListContainer, err)
+}
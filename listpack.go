@@ -0,0 +1,195 @@
+package rdbtools
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+type listpackOnLenCallback func(length int64) error
+type listpackOnElementCallback func(element interface{}) error
+
+// Read a listpack (Redis >= 7.0).
+//
+// Unlike a zip list, a listpack entry stores its own length after its data (the backlen),
+// so the entries can be read one after the other until the terminating 0xFF byte.
+func (p *parser) readListpack(r io.Reader, onLenCallback listpackOnLenCallback, onElementCallback listpackOnElementCallback) error {
+	var lpBytes uint32
+	var lpLen uint16
+
+	if err := binary.Read(r, binary.LittleEndian, &lpBytes); err != nil {
+		return err
+	}
+
+	if err := binary.Read(r, binary.LittleEndian, &lpLen); err != nil {
+		return err
+	}
+
+	// A length of 65535 means the listpack has too many elements for the header,
+	// so we have to read all of them before knowing it.
+	var elements []interface{}
+	if lpLen == 65535 {
+		elements = make([]interface{}, 0)
+	} else {
+		if err := onLenCallback(int64(lpLen)); err != nil {
+			return err
+		}
+	}
+
+	for {
+		data, end, err := p.readListpackEntry(r)
+		if err != nil {
+			return err
+		}
+		if end {
+			break
+		}
+
+		if lpLen == 65535 {
+			elements = append(elements, data)
+		} else {
+			if err := onElementCallback(data); err != nil {
+				return err
+			}
+		}
+	}
+
+	if lpLen == 65535 {
+		if err := onLenCallback(int64(len(elements))); err != nil {
+			return err
+		}
+		for _, e := range elements {
+			if err := onElementCallback(e); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Read a single listpack entry, including its backlen.
+// Returns true if the byte read is the listpack terminator.
+func (p *parser) readListpackEntry(r io.Reader) (interface{}, bool, error) {
+	_, err := io.ReadFull(r, p.scratch[0:1])
+	if err != nil {
+		return nil, false, err
+	}
+
+	flag := p.scratch[0]
+	var data interface{}
+	var entryLen int64
+
+	if (flag & 0x80) == 0 {
+		// 7 bit unsigned integer
+		data = int64(flag & 0x7F)
+		entryLen = 1
+	} else if (flag & 0xC0) == 0x80 {
+		// String with length <= 63 bytes
+		length := int64(flag & 0x3F)
+		data, err = readBytes(r, uint64(length))
+		if err != nil {
+			return nil, false, err
+		}
+		entryLen = 1 + length
+	} else if (flag & 0xE0) == 0xC0 {
+		// 13 bit signed integer
+		_, err = io.ReadFull(r, p.scratch[0:1])
+		if err != nil {
+			return nil, false, err
+		}
+
+		v := int64(flag&0x1F)<<8 | int64(p.scratch[0])
+		if v >= 1<<12 {
+			v -= 1 << 13
+		}
+		data = v
+		entryLen = 2
+	} else if (flag & 0xF0) == 0xE0 {
+		// String with length <= 4095 bytes
+		_, err = io.ReadFull(r, p.scratch[0:1])
+		if err != nil {
+			return nil, false, err
+		}
+
+		length := (int64(flag&0x0F) << 8) | int64(p.scratch[0])
+		data, err = readBytes(r, uint64(length))
+		if err != nil {
+			return nil, false, err
+		}
+		entryLen = 2 + length
+	} else if flag == 0xF0 {
+		// String with a 32 bit length
+		var tmp uint32
+		if err := binary.Read(r, binary.LittleEndian, &tmp); err != nil {
+			return nil, false, err
+		}
+
+		length := int64(tmp)
+		data, err = readBytes(r, uint64(length))
+		if err != nil {
+			return nil, false, err
+		}
+		entryLen = 5 + length
+	} else if flag == 0xF1 {
+		// int16
+		var tmp int16
+		if err := binary.Read(r, binary.LittleEndian, &tmp); err != nil {
+			return nil, false, err
+		}
+		data = int64(tmp)
+		entryLen = 3
+	} else if flag == 0xF2 {
+		// int24
+		ab, err := readBytes(r, 3)
+		if err != nil {
+			return nil, false, err
+		}
+
+		tmp := uint32(ab[0])<<8 | uint32(ab[1])<<16 | uint32(ab[2])<<24
+		data = int64(int32(tmp) >> 8)
+		entryLen = 4
+	} else if flag == 0xF3 {
+		// int32
+		var tmp int32
+		if err := binary.Read(r, binary.LittleEndian, &tmp); err != nil {
+			return nil, false, err
+		}
+		data = int64(tmp)
+		entryLen = 5
+	} else if flag == 0xF4 {
+		// int64
+		var tmp int64
+		if err := binary.Read(r, binary.LittleEndian, &tmp); err != nil {
+			return nil, false, err
+		}
+		data = tmp
+		entryLen = 9
+	} else if flag == 0xFF {
+		return nil, true, nil
+	} else {
+		return nil, false, ErrUnexpectedListpackEncoding
+	}
+
+	// Skip the backlen, which we don't use
+	if _, err := readBytes(r, uint64(listpackBacklenSize(entryLen))); err != nil {
+		return nil, false, err
+	}
+
+	return data, false, nil
+}
+
+// Returns the number of bytes used to encode the backlen of an entry of length l
+func listpackBacklenSize(l int64) int64 {
+	switch {
+	case l <= 127:
+		return 1
+	case l < 16383:
+		return 2
+	case l < 2097151:
+		return 3
+	case l < 268435455:
+		return 4
+	default:
+		return 5
+	}
+}
@@ -0,0 +1,203 @@
+package rdbtools
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"strings"
+	"testing"
+)
+
+// Build a listpack containing the entries, which must be strings or int64
+func newListpack(entries ...interface{}) []byte {
+	var body bytes.Buffer
+	for _, e := range entries {
+		var entry bytes.Buffer
+		switch v := e.(type) {
+		case string:
+			switch {
+			case len(v) <= 63:
+				entry.WriteByte(0x80 | byte(len(v)))
+			case len(v) <= 4095:
+				entry.WriteByte(0xE0 | byte(len(v)>>8))
+				entry.WriteByte(byte(len(v)))
+			default:
+				entry.WriteByte(0xF0)
+				binary.Write(&entry, binary.LittleEndian, uint32(len(v)))
+			}
+			entry.WriteString(v)
+		case int64:
+			switch {
+			case v >= 0 && v <= 127:
+				entry.WriteByte(byte(v))
+			case v >= -4096 && v <= 4095:
+				u := uint16(v) & 0x1FFF
+				entry.WriteByte(0xC0 | byte(u>>8))
+				entry.WriteByte(byte(u))
+			default:
+				entry.WriteByte(0xF4)
+				binary.Write(&entry, binary.LittleEndian, v)
+			}
+		}
+
+		body.Write(entry.Bytes())
+		body.Write(listpackBacklen(entry.Len()))
+	}
+
+	var lp bytes.Buffer
+	binary.Write(&lp, binary.LittleEndian, uint32(6+body.Len()+1))
+	binary.Write(&lp, binary.LittleEndian, uint16(len(entries)))
+	lp.Write(body.Bytes())
+	lp.WriteByte(0xFF)
+
+	return lp.Bytes()
+}
+
+// Encode the backlen of an entry of length l
+func listpackBacklen(l int) []byte {
+	n := listpackBacklenSize(int64(l))
+	b := make([]byte, n)
+	for i := int64(0); i < n; i++ {
+		b[n-1-i] = byte(l>>(7*uint(i))) & 0x7F
+		if i < n-1 {
+			b[n-1-i] |= 0x80
+		}
+	}
+	return b
+}
+
+func readListpackElements(t *testing.T, data []byte) (int64, []interface{}) {
+	p := &parser{}
+
+	var length int64
+	elements := make([]interface{}, 0)
+	onLenCallback := func(l int64) error {
+		length = l
+		return nil
+	}
+	onElementCallback := func(e interface{}) error {
+		elements = append(elements, e)
+		return nil
+	}
+
+	err := p.readListpack(bufio.NewReader(bytes.NewReader(data)), onLenCallback, onElementCallback)
+	ok(t, err)
+
+	return length, elements
+}
+
+func TestReadListpackStrings(t *testing.T) {
+	l, elements := readListpackElements(t, newListpack("foobar", strings.Repeat("a", 1000), strings.Repeat("b", 5000)))
+	equals(t, int64(3), l)
+	equals(t, 3, len(elements))
+	equals(t, "foobar", DataToString(elements[0]))
+	equals(t, strings.Repeat("a", 1000), DataToString(elements[1]))
+	equals(t, strings.Repeat("b", 5000), DataToString(elements[2]))
+}
+
+func TestReadListpackIntegers(t *testing.T) {
+	l, elements := readListpackElements(t, newListpack(int64(1), int64(127), int64(-1), int64(4095), int64(-4096), int64(1<<40)))
+	equals(t, int64(6), l)
+	equals(t, []interface{}{int64(1), int64(127), int64(-1), int64(4095), int64(-4096), int64(1 << 40)}, elements)
+}
+
+func TestReadListpackFixedSizeIntegers(t *testing.T) {
+	var buffer bytes.Buffer
+
+	br := bufio.NewWriter(&buffer)
+	binary.Write(br, binary.LittleEndian, uint32(0)) // lpBytes
+	binary.Write(br, binary.LittleEndian, uint16(3)) // lpLen
+
+	br.WriteByte(0xF1) // int16
+	binary.Write(br, binary.LittleEndian, int16(-300))
+	br.WriteByte(3) // backlen
+
+	br.WriteByte(0xF2)                 // int24
+	br.Write([]byte{0x00, 0x00, 0x80}) // -8388608
+	br.WriteByte(4)                    // backlen
+
+	br.WriteByte(0xF3) // int32
+	binary.Write(br, binary.LittleEndian, int32(100000))
+	br.WriteByte(5) // backlen
+
+	br.WriteByte(0xFF)
+	br.Flush()
+
+	l, elements := readListpackElements(t, buffer.Bytes())
+	equals(t, int64(3), l)
+	equals(t, []interface{}{int64(-300), int64(-8388608), int64(100000)}, elements)
+}
+
+func TestReadListpackUnknownLength(t *testing.T) {
+	data := newListpack("a", "b", int64(3))
+	binary.LittleEndian.PutUint16(data[4:], 65535)
+
+	l, elements := readListpackElements(t, data)
+	equals(t, int64(3), l)
+	equals(t, 3, len(elements))
+}
+
+func TestReadListpackNoLpBytes(t *testing.T) {
+	var buffer bytes.Buffer
+
+	p := &parser{}
+	err := p.readListpack(bufio.NewReader(&buffer), nil, nil)
+	equals(t, io.EOF, err)
+}
+
+func TestReadListpackNoLpLen(t *testing.T) {
+	var buffer bytes.Buffer
+
+	br := bufio.NewWriter(&buffer)
+	binary.Write(br, binary.LittleEndian, uint32(0))
+	br.Flush()
+
+	p := &parser{}
+	err := p.readListpack(bufio.NewReader(&buffer), nil, nil)
+	equals(t, io.EOF, err)
+}
+
+func TestReadListpackNoTerminator(t *testing.T) {
+	data := newListpack("a")
+
+	p := &parser{}
+	onLenCallback := func(l int64) error { return nil }
+	onElementCallback := func(e interface{}) error { return nil }
+
+	err := p.readListpack(bufio.NewReader(bytes.NewReader(data[:len(data)-1])), onLenCallback, onElementCallback)
+	equals(t, io.EOF, err)
+}
+
+func TestReadListpackNoBacklen(t *testing.T) {
+	var buffer bytes.Buffer
+
+	br := bufio.NewWriter(&buffer)
+	br.WriteByte(1)
+	br.Flush()
+
+	p := &parser{}
+	_, _, err := p.readListpackEntry(bufio.NewReader(&buffer))
+	equals(t, io.EOF, err)
+}
+
+func TestReadListpackUnexpectedEncoding(t *testing.T) {
+	var buffer bytes.Buffer
+
+	br := bufio.NewWriter(&buffer)
+	br.WriteByte(0xF5)
+	br.Flush()
+
+	p := &parser{}
+	_, _, err := p.readListpackEntry(bufio.NewReader(&buffer))
+	equals(t, ErrUnexpectedListpackEncoding, err)
+}
+
+func TestListpackBacklenSize(t *testing.T) {
+	equals(t, int64(1), listpackBacklenSize(127))
+	equals(t, int64(2), listpackBacklenSize(128))
+	equals(t, int64(2), listpackBacklenSize(16382))
+	equals(t, int64(3), listpackBacklenSize(16383))
+	equals(t, int64(4), listpackBacklenSize(2097151))
+	equals(t, int64(5), listpackBacklenSize(268435455))
+}
@@ -1,39 +1,56 @@
 package rdbtools
 
-func lzfDecompress(data []byte, ulen int64) []byte {
-	output := make([]byte, ulen)
+// The largest number of bytes a byte of LZF compressed data expands to,
+// a back reference of 3 bytes being expanded to up to 264 bytes
+const lzfMaxRatio = 88
 
-	if len(data) <= 0 {
-		return output
+func lzfDecompress(data []byte, ulen uint64) ([]byte, error) {
+	if ulen > uint64(len(data))*lzfMaxRatio {
+		return nil, ErrInvalidLZFData
 	}
 
-	i := uint32(0)
-	o := uint32(0)
+	output := make([]byte, 0, ulen)
 
-	for i < uint32(len(data)) {
-		var ctrl uint32 = uint32(data[i])
+	i := 0
+	for i < len(data) {
+		ctrl := int(data[i])
 		i++
 		if ctrl < 32 {
-			copy(output[o:], data[i:i+ctrl+1])
-			i += ctrl + 1
-			o += ctrl + 1
+			end := i + ctrl + 1
+			if end > len(data) || uint64(len(output)+ctrl+1) > ulen {
+				return nil, ErrInvalidLZFData
+			}
+
+			output = append(output, data[i:end]...)
+			i = end
 		} else {
-			var length uint32 = uint32(ctrl >> 5)
+			length := ctrl >> 5
 			if length == 7 {
-				length += uint32(data[i])
+				if i >= len(data) {
+					return nil, ErrInvalidLZFData
+				}
+				length += int(data[i])
 				i++
 			}
+			if i >= len(data) {
+				return nil, ErrInvalidLZFData
+			}
 
-			var ref uint32 = uint32(o) - uint32(ctrl&0x1F<<8) - uint32(data[i]) - 1
-
+			ref := len(output) - (ctrl&0x1F)<<8 - int(data[i]) - 1
 			i++
-			for j := uint32(0); j < length+2; j++ {
-				output[o] = output[ref]
-				ref++
-				o++
+			if ref < 0 || uint64(len(output)+length+2) > ulen {
+				return nil, ErrInvalidLZFData
+			}
+
+			for j := 0; j < length+2; j++ {
+				output = append(output, output[ref+j])
 			}
 		}
 	}
 
-	return output
+	if uint64(len(output)) != ulen {
+		return nil, ErrInvalidLZFData
+	}
+
+	return output, nil
 }
@@ -7,9 +7,10 @@ import (
 
 func TestLzfDecompress(t *testing.T) {
 	data := []byte{1, 97, 97, 224, 246, 0, 1, 97, 97}
-	ulen := int64(259)
+	ulen := uint64(259)
 
-	output := lzfDecompress(data, ulen)
+	output, err := lzfDecompress(data, ulen)
+	ok(t, err)
 	expected := strings.Repeat("a", int(ulen))
 	if string(output) != expected {
 		t.Errorf("expected %s but got %s", expected, string(output))
@@ -17,8 +18,30 @@ func TestLzfDecompress(t *testing.T) {
 }
 
 func TestLzfDecompressNoData(t *testing.T) {
-	output := lzfDecompress([]byte{}, 0)
+	output, err := lzfDecompress([]byte{}, 0)
+	ok(t, err)
 	if len(output) != 0 {
 		t.Errorf("expected empty slice but got %s", string(output))
 	}
 }
+
+func TestLzfDecompressInvalid(t *testing.T) {
+	testCases := []struct {
+		data []byte
+		ulen uint64
+	}{
+		{[]byte{1, 97, 97}, 1 << 62},                 // Length too large for the data
+		{[]byte{1, 97, 97}, 3},                       // Length larger than the data
+		{[]byte{1, 97, 97, 224, 246, 0}, 2},          // Length smaller than the data
+		{[]byte{5, 97}, 6},                           // Literal run past the data
+		{[]byte{224}, 1},                             // Back reference without its length
+		{[]byte{32}, 1},                              // Back reference without its offset
+		{[]byte{1, 97, 97, 32, 5}, 5},                // Back reference before the data
+		{[]byte{1, 97, 97, 224, 246, 0, 1, 97}, 259}, // Literal run past the data after a back reference
+	}
+
+	for _, tc := range testCases {
+		_, err := lzfDecompress(tc.data, tc.ulen)
+		equals(t, ErrInvalidLZFData, err)
+	}
+}
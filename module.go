@@ -0,0 +1,324 @@
+package rdbtools
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"sync"
+)
+
+// Represents a value of a module type (Redis >= 4.0).
+type ModuleObject struct {
+	Key        KeyObject
+	ModuleName string      // The 9 characters name of the module type, for example "ReJSON-RL"
+	Version    int         // The encoding version of the module type
+	Value      interface{} // The value returned by the registered decoder, nil if there is none
+	Raw        []byte      // The serialized value, only set if there is no registered decoder
+}
+
+// Returns a visualization of the module object
+func (m ModuleObject) String() string {
+	return fmt.Sprintf("ModuleObject{Key: %s, ModuleName: %s, Version: %d}", DataToString(m.Key), m.ModuleName, m.Version)
+}
+
+// A ModuleDecoder decodes a value of a module type, reading it with r.
+// version is the encoding version of the module type the value was saved with.
+type ModuleDecoder func(r *ModuleReader, version int) (interface{}, error)
+
+var (
+	moduleDecodersMu sync.RWMutex
+	moduleDecoders   = make(map[string]ModuleDecoder)
+)
+
+// Register a decoder for the values of the module type named name.
+//
+// Without a decoder, values saved by Redis >= 5.0 are skipped and sent with their raw serialization,
+// while values saved by older versions can't be parsed.
+func RegisterModuleDecoder(name string, fn ModuleDecoder) {
+	moduleDecodersMu.Lock()
+	defer moduleDecodersMu.Unlock()
+
+	moduleDecoders[name] = fn
+}
+
+func lookupModuleDecoder(name string) ModuleDecoder {
+	moduleDecodersMu.RLock()
+	defer moduleDecodersMu.RUnlock()
+
+	return moduleDecoders[name]
+}
+
+// Module value opcodes, prefixing each value since Redis 5.0
+const (
+	moduleOpcodeEOF    = 0
+	moduleOpcodeSInt   = 1
+	moduleOpcodeUInt   = 2
+	moduleOpcodeFloat  = 3
+	moduleOpcodeDouble = 4
+	moduleOpcodeString = 5
+)
+
+// A ModuleReader reads the values saved by a module, in the order the module saved them.
+type ModuleReader struct {
+	p       *parser
+	r       io.Reader
+	opcodes bool // Whether each value is prefixed by its opcode
+}
+
+func (m *ModuleReader) checkOpcode(expected uint64) error {
+	if !m.opcodes {
+		return nil
+	}
+
+	opcode, e, err := m.p.readLen(m.r)
+	if err != nil {
+		return err
+	}
+	if e || opcode != expected {
+		return ErrUnexpectedModuleOpcode
+	}
+
+	return nil
+}
+
+// Read an unsigned integer, saved with RedisModule_SaveUnsigned
+func (m *ModuleReader) ReadUnsigned() (uint64, error) {
+	if err := m.checkOpcode(moduleOpcodeUInt); err != nil {
+		return 0, err
+	}
+
+	v, e, err := m.p.readLen(m.r)
+	if err != nil {
+		return 0, err
+	}
+	if e {
+		return 0, ErrUnexpectedEncodedLength
+	}
+
+	return v, nil
+}
+
+// Read a signed integer, saved with RedisModule_SaveSigned
+func (m *ModuleReader) ReadSigned() (int64, error) {
+	if err := m.checkOpcode(moduleOpcodeSInt); err != nil {
+		return 0, err
+	}
+
+	v, e, err := m.p.readLen(m.r)
+	if err != nil {
+		return 0, err
+	}
+	if e {
+		return 0, ErrUnexpectedEncodedLength
+	}
+
+	return int64(v), nil
+}
+
+// Read a float, saved with RedisModule_SaveFloat
+func (m *ModuleReader) ReadFloat() (float32, error) {
+	if err := m.checkOpcode(moduleOpcodeFloat); err != nil {
+		return 0, err
+	}
+
+	var v uint32
+	if err := binary.Read(m.r, binary.LittleEndian, &v); err != nil {
+		return 0, err
+	}
+
+	return math.Float32frombits(v), nil
+}
+
+// Read a double, saved with RedisModule_SaveDouble
+func (m *ModuleReader) ReadDouble() (float64, error) {
+	if err := m.checkOpcode(moduleOpcodeDouble); err != nil {
+		return 0, err
+	}
+
+	return readBinaryDoubleValue(m.r)
+}
+
+// Read a string, saved with RedisModule_SaveString or RedisModule_SaveStringBuffer
+func (m *ModuleReader) ReadString() ([]byte, error) {
+	if err := m.checkOpcode(moduleOpcodeString); err != nil {
+		return nil, err
+	}
+
+	v, err := m.p.readString(m.r)
+	if err != nil {
+		return nil, err
+	}
+
+	if b, ok := v.([]byte); ok {
+		return b, nil
+	}
+
+	return []byte(DataToString(v)), nil
+}
+
+// Read a value of a module type. opcodes is true for RDB type 7 (Redis >= 5.0), where each
+// value saved by the module is prefixed by an opcode, which makes the value skippable.
+func (p *parser) readModule(key KeyObject, r io.Reader, opcodes bool) error {
+	id, e, err := p.readLen(r)
+	if err != nil {
+		return err
+	}
+	if e {
+		return ErrUnexpectedEncodedLength
+	}
+
+	name, version := decodeModuleID(id)
+	obj := ModuleObject{Key: key, ModuleName: name, Version: version}
+
+	if decoder := lookupModuleDecoder(name); decoder != nil {
+		obj.Value, err = decoder(&ModuleReader{p: p, r: r, opcodes: opcodes}, version)
+		if err != nil {
+			return err
+		}
+
+		if opcodes {
+			if err := (&ModuleReader{p: p, r: r, opcodes: true}).checkOpcode(moduleOpcodeEOF); err != nil {
+				return err
+			}
+		}
+	} else {
+		if !opcodes {
+			return ErrUnknownModuleType
+		}
+
+		var buf bytes.Buffer
+		if err := p.skipModuleValue(io.TeeReader(r, &buf)); err != nil {
+			return err
+		}
+		obj.Raw = buf.Bytes()
+	}
+
+	if err := p.h.Module(obj); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Skip an opcode prefixed module value, up to and including its EOF opcode
+func (p *parser) skipModuleValue(r io.Reader) error {
+	for {
+		opcode, e, err := p.readLen(r)
+		if err != nil {
+			return err
+		}
+		if e {
+			return ErrUnexpectedEncodedLength
+		}
+
+		switch opcode {
+		case moduleOpcodeEOF:
+			return nil
+		case moduleOpcodeSInt, moduleOpcodeUInt:
+			if _, _, err := p.readLen(r); err != nil {
+				return err
+			}
+		case moduleOpcodeFloat:
+			if _, err := readBytes(r, 4); err != nil {
+				return err
+			}
+		case moduleOpcodeDouble:
+			if _, err := readBytes(r, 8); err != nil {
+				return err
+			}
+		case moduleOpcodeString:
+			if _, err := p.readString(r); err != nil {
+				return err
+			}
+		default:
+			return ErrUnexpectedModuleOpcode
+		}
+	}
+}
+
+// When flags of module auxiliary data
+const (
+	ModuleAuxBeforeRDB = 1 << 0 // The data is saved before the keyspace
+	ModuleAuxAfterRDB  = 1 << 1 // The data is saved after the keyspace
+)
+
+// Represents auxiliary data saved by a module type, usually some global state of the module (Redis >= 5.0).
+type ModuleAux struct {
+	ModuleName string // The 9 characters name of the module type
+	Version    int    // The encoding version of the module type
+	When       int    // Either ModuleAuxBeforeRDB or ModuleAuxAfterRDB
+	Raw        []byte // The serialized data, up to and including its EOF opcode
+}
+
+// Returns a visualization of the module auxiliary data
+func (m ModuleAux) String() string {
+	return fmt.Sprintf("ModuleAux{ModuleName: %s, Version: %d, When: %d}", m.ModuleName, m.Version, m.When)
+}
+
+// Read the auxiliary data of a module type, saved with the MODULE_AUX opcode.
+// The when flag is saved as an opcode prefixed unsigned integer and followed by the opcode prefixed data.
+func (p *parser) readModuleAux(r io.Reader) error {
+	id, e, err := p.readLen(r)
+	if err != nil {
+		return err
+	}
+	if e {
+		return ErrUnexpectedEncodedLength
+	}
+
+	name, version := decodeModuleID(id)
+	aux := ModuleAux{ModuleName: name, Version: version}
+
+	when, err := (&ModuleReader{p: p, r: r, opcodes: true}).ReadUnsigned()
+	if err != nil {
+		return err
+	}
+	aux.When = int(when)
+
+	var buf bytes.Buffer
+	if err := p.skipModuleValue(io.TeeReader(r, &buf)); err != nil {
+		return err
+	}
+	aux.Raw = buf.Bytes()
+
+	if err := p.h.ModuleAux(aux); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+const moduleIDCharset = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-_"
+
+// Decode a 64 bit module type ID: the 9 characters of the name use 6 bits each,
+// and the remaining 10 bits hold the encoding version.
+func decodeModuleID(id uint64) (string, int) {
+	name := make([]byte, 9)
+	for i := range name {
+		name[i] = moduleIDCharset[(id>>(64-6*uint(i+1)))&63]
+	}
+
+	return string(name), int(id & 1023)
+}
+
+// Encode a module type ID, the reverse of decodeModuleID.
+// Returns false if name isn't a valid module type name or version doesn't fit in 10 bits.
+func encodeModuleID(name string, version int) (uint64, bool) {
+	if len(name) != 9 || version < 0 || version > 1023 {
+		return 0, false
+	}
+
+	var id uint64
+	for i := 0; i < len(name); i++ {
+		c := strings.IndexByte(moduleIDCharset, name[i])
+		if c < 0 {
+			return 0, false
+		}
+		id = id<<6 | uint64(c)
+	}
+
+	return id<<10 | uint64(version), true
+}
@@ -0,0 +1,293 @@
+package rdbtools
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"testing"
+)
+
+// Encode a module type name and encoding version, which must be valid
+func mustEncodeModuleID(name string, version int) uint64 {
+	id, ok := encodeModuleID(name, version)
+	if !ok {
+		panic("invalid module type " + name)
+	}
+
+	return id
+}
+
+func TestModuleObjectString(t *testing.T) {
+	m := ModuleObject{Key: KeyObject{Key: "foobar"}, ModuleName: "ReJSON-RL", Version: 3}
+	equals(t, "ModuleObject{Key: foobar, ModuleName: ReJSON-RL, Version: 3}", m.String())
+}
+
+func TestDecodeModuleID(t *testing.T) {
+	name, version := decodeModuleID(mustEncodeModuleID("ReJSON-RL", 3))
+	equals(t, "ReJSON-RL", name)
+	equals(t, 3, version)
+
+	name, version = decodeModuleID(mustEncodeModuleID("MBbloom--", 1023))
+	equals(t, "MBbloom--", name)
+	equals(t, 1023, version)
+}
+
+func TestEncodeModuleIDInvalid(t *testing.T) {
+	_, ok := encodeModuleID("ReJSON", 1)
+	equals(t, false, ok)
+
+	_, ok = encodeModuleID("ReJSON.RL", 1)
+	equals(t, false, ok)
+
+	_, ok = encodeModuleID("ReJSON-RL", 1024)
+	equals(t, false, ok)
+}
+
+func writeTestModuleValue(w io.Writer) {
+	writeLen(w, moduleOpcodeUInt)
+	writeLen(w, 42)
+	writeLen(w, moduleOpcodeSInt)
+	writeLen(w, uint64(1<<63))
+	writeLen(w, moduleOpcodeFloat)
+	binary.Write(w, binary.LittleEndian, math.Float32bits(1.5))
+	writeLen(w, moduleOpcodeDouble)
+	binary.Write(w, binary.LittleEndian, math.Float64bits(2.5))
+	writeLen(w, moduleOpcodeString)
+	writeString(w, "foobar")
+	writeLen(w, moduleOpcodeEOF)
+}
+
+func TestReadModuleWithoutDecoder(t *testing.T) {
+	var value bytes.Buffer
+	writeTestModuleValue(&value)
+
+	var buffer bytes.Buffer
+	writeLen(&buffer, mustEncodeModuleID("nodecoder", 2))
+	buffer.Write(value.Bytes())
+
+	ctx := ParserContext{ModuleObjectCh: make(chan ModuleObject)}
+	p := &parser{h: newChannelHandler(ctx)}
+
+	go func() {
+		m := <-ctx.ModuleObjectCh
+		equals(t, "module", DataToString(m.Key))
+		equals(t, "nodecoder", m.ModuleName)
+		equals(t, 2, m.Version)
+		equals(t, nil, m.Value)
+		equals(t, value.Bytes(), m.Raw)
+	}()
+
+	err := p.readModule(KeyObject{Key: []byte("module")}, bufio.NewReader(&buffer), true)
+	ok(t, err)
+}
+
+type testModuleValue struct {
+	u uint64
+	s int64
+	f float32
+	d float64
+	b string
+}
+
+func decodeTestModuleValue(r *ModuleReader, version int) (interface{}, error) {
+	var v testModuleValue
+	var err error
+
+	if v.u, err = r.ReadUnsigned(); err != nil {
+		return nil, err
+	}
+	if v.s, err = r.ReadSigned(); err != nil {
+		return nil, err
+	}
+	if v.f, err = r.ReadFloat(); err != nil {
+		return nil, err
+	}
+	if v.d, err = r.ReadDouble(); err != nil {
+		return nil, err
+	}
+
+	b, err := r.ReadString()
+	if err != nil {
+		return nil, err
+	}
+	v.b = string(b)
+
+	return v, nil
+}
+
+func TestReadModuleWithDecoder(t *testing.T) {
+	RegisterModuleDecoder("decoder-1", decodeTestModuleValue)
+
+	var buffer bytes.Buffer
+	writeLen(&buffer, mustEncodeModuleID("decoder-1", 1))
+	writeTestModuleValue(&buffer)
+
+	ctx := ParserContext{ModuleObjectCh: make(chan ModuleObject)}
+	p := &parser{h: newChannelHandler(ctx)}
+
+	go func() {
+		m := <-ctx.ModuleObjectCh
+		equals(t, "decoder-1", m.ModuleName)
+		equals(t, 1, m.Version)
+		equals(t, testModuleValue{u: 42, s: math.MinInt64, f: 1.5, d: 2.5, b: "foobar"}, m.Value)
+		equals(t, []byte(nil), m.Raw)
+	}()
+
+	err := p.readModule(KeyObject{Key: []byte("module")}, bufio.NewReader(&buffer), true)
+	ok(t, err)
+}
+
+func TestReadModuleWithDecoderWithoutOpcodes(t *testing.T) {
+	RegisterModuleDecoder("decoder-2", decodeTestModuleValue)
+
+	var buffer bytes.Buffer
+	writeLen(&buffer, mustEncodeModuleID("decoder-2", 1))
+	writeLen(&buffer, 42)
+	writeLen(&buffer, 1)
+	binary.Write(&buffer, binary.LittleEndian, math.Float32bits(1.5))
+	binary.Write(&buffer, binary.LittleEndian, math.Float64bits(2.5))
+	buffer.Write([]byte{0xC0, 12}) // Integer encoded string
+
+	ctx := ParserContext{ModuleObjectCh: make(chan ModuleObject)}
+	p := &parser{h: newChannelHandler(ctx)}
+
+	go func() {
+		m := <-ctx.ModuleObjectCh
+		equals(t, testModuleValue{u: 42, s: 1, f: 1.5, d: 2.5, b: "12"}, m.Value)
+	}()
+
+	err := p.readModule(KeyObject{Key: []byte("module")}, bufio.NewReader(&buffer), false)
+	ok(t, err)
+}
+
+func TestReadModuleWithDecoderMissingEOF(t *testing.T) {
+	RegisterModuleDecoder("decoder-3", func(r *ModuleReader, version int) (interface{}, error) {
+		return r.ReadUnsigned()
+	})
+
+	var buffer bytes.Buffer
+	writeLen(&buffer, mustEncodeModuleID("decoder-3", 1))
+	writeLen(&buffer, moduleOpcodeUInt)
+	writeLen(&buffer, 42)
+	writeLen(&buffer, moduleOpcodeUInt)
+	writeLen(&buffer, 42)
+
+	p := &parser{}
+	err := p.readModule(KeyObject{Key: []byte("module")}, bufio.NewReader(&buffer), true)
+	equals(t, ErrUnexpectedModuleOpcode, err)
+}
+
+func TestReadModuleWithDecoderUnexpectedOpcode(t *testing.T) {
+	RegisterModuleDecoder("decoder-4", func(r *ModuleReader, version int) (interface{}, error) {
+		return r.ReadDouble()
+	})
+
+	var buffer bytes.Buffer
+	writeLen(&buffer, mustEncodeModuleID("decoder-4", 1))
+	writeLen(&buffer, moduleOpcodeUInt)
+	writeLen(&buffer, 42)
+
+	p := &parser{}
+	err := p.readModule(KeyObject{Key: []byte("module")}, bufio.NewReader(&buffer), true)
+	equals(t, ErrUnexpectedModuleOpcode, err)
+}
+
+func TestReadModuleUnknownType(t *testing.T) {
+	var buffer bytes.Buffer
+	writeLen(&buffer, mustEncodeModuleID("nodecoder", 1))
+
+	p := &parser{}
+	err := p.readModule(KeyObject{Key: []byte("module")}, bufio.NewReader(&buffer), false)
+	equals(t, ErrUnknownModuleType, err)
+}
+
+func TestReadModuleNoData(t *testing.T) {
+	var buffer bytes.Buffer
+
+	p := &parser{}
+	err := p.readModule(KeyObject{Key: []byte("module")}, bufio.NewReader(&buffer), true)
+	equals(t, io.EOF, err)
+}
+
+func TestSkipModuleValueNoEOF(t *testing.T) {
+	var buffer bytes.Buffer
+	writeLen(&buffer, moduleOpcodeUInt)
+	writeLen(&buffer, 42)
+
+	p := &parser{}
+	err := p.skipModuleValue(bufio.NewReader(&buffer))
+	equals(t, io.EOF, err)
+}
+
+func TestSkipModuleValueUnexpectedOpcode(t *testing.T) {
+	var buffer bytes.Buffer
+	writeLen(&buffer, 6)
+
+	p := &parser{}
+	err := p.skipModuleValue(bufio.NewReader(&buffer))
+	equals(t, ErrUnexpectedModuleOpcode, err)
+}
+
+func TestModuleAuxString(t *testing.T) {
+	m := ModuleAux{ModuleName: "ft-index0", Version: 2, When: ModuleAuxAfterRDB}
+	equals(t, "ModuleAux{ModuleName: ft-index0, Version: 2, When: 2}", m.String())
+}
+
+func TestReadModuleAux(t *testing.T) {
+	var value bytes.Buffer
+	writeTestModuleValue(&value)
+
+	var buffer bytes.Buffer
+	writeLen(&buffer, mustEncodeModuleID("ft-index0", 2))
+	writeLen(&buffer, moduleOpcodeUInt)
+	writeLen(&buffer, ModuleAuxBeforeRDB)
+	buffer.Write(value.Bytes())
+
+	ctx := ParserContext{ModuleAuxCh: make(chan ModuleAux)}
+	p := &parser{h: newChannelHandler(ctx)}
+
+	go func() {
+		m := <-ctx.ModuleAuxCh
+		equals(t, "ft-index0", m.ModuleName)
+		equals(t, 2, m.Version)
+		equals(t, ModuleAuxBeforeRDB, m.When)
+		equals(t, value.Bytes(), m.Raw)
+	}()
+
+	err := p.readModuleAux(bufio.NewReader(&buffer))
+	ok(t, err)
+}
+
+func TestReadModuleAuxNoWhenOpcode(t *testing.T) {
+	var buffer bytes.Buffer
+	writeLen(&buffer, mustEncodeModuleID("ft-index0", 2))
+	writeLen(&buffer, moduleOpcodeString)
+	writeString(&buffer, "foobar")
+
+	p := &parser{}
+	err := p.readModuleAux(bufio.NewReader(&buffer))
+	equals(t, ErrUnexpectedModuleOpcode, err)
+}
+
+func TestReadModuleAuxNoEOF(t *testing.T) {
+	var buffer bytes.Buffer
+	writeLen(&buffer, mustEncodeModuleID("ft-index0", 2))
+	writeLen(&buffer, moduleOpcodeUInt)
+	writeLen(&buffer, ModuleAuxAfterRDB)
+	writeLen(&buffer, moduleOpcodeString)
+	writeString(&buffer, "foobar")
+
+	p := &parser{}
+	err := p.readModuleAux(bufio.NewReader(&buffer))
+	equals(t, io.EOF, err)
+}
+
+func TestReadModuleAuxNoData(t *testing.T) {
+	var buffer bytes.Buffer
+
+	p := &parser{}
+	err := p.readModuleAux(bufio.NewReader(&buffer))
+	equals(t, io.EOF, err)
+}
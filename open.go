@@ -0,0 +1,166 @@
+package rdbtools
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"sync"
+)
+
+// Returns a reader of the data decompressed from r
+type Decompressor func(r io.Reader) (io.ReadCloser, error)
+
+type registeredDecompressor struct {
+	magic      []byte
+	decompress Decompressor
+}
+
+var (
+	decompressorsMu sync.RWMutex
+	decompressors   = []registeredDecompressor{
+		{magic: []byte{0x1f, 0x8b}, decompress: func(r io.Reader) (io.ReadCloser, error) { return gzip.NewReader(r) }},
+	}
+)
+
+// The magic bytes of zstd compressed files, which need a decompressor to be registered,
+// Open returning ErrUnsupportedCompression otherwise:
+//
+//	rdbtools.RegisterDecompressor(rdbtools.ZstdMagic, func(r io.Reader) (io.ReadCloser, error) {
+//		d, err := zstd.NewReader(r)
+//		if err != nil {
+//			return nil, err
+//		}
+//		return d.IOReadCloser(), nil
+//	})
+var ZstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+// Register a decompressor used by Open for the files starting with magic.
+// gzip is supported without registration.
+func RegisterDecompressor(magic []byte, d Decompressor) {
+	decompressorsMu.Lock()
+	defer decompressorsMu.Unlock()
+
+	decompressors = append(decompressors, registeredDecompressor{magic: magic, decompress: d})
+}
+
+// The length of the data sniffed to detect a tar archive, whose magic string is at offset 257
+const tarSniffLen = 262
+
+// Open the RDB file at path for Parse. The file can be compressed with a registered
+// decompressor or stored in a tar archive, in which case the first file of the archive
+// which is a RDB file is read.
+// The checksum of the RDB file is still checked by Parse, on the decompressed data.
+func Open(path string) (io.ReadCloser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	rc := &multiCloser{closers: []io.Closer{f}}
+	br, err := rc.unwrap(f)
+	if err != nil {
+		rc.Close()
+		return nil, err
+	}
+	rc.Reader = br
+
+	return rc, nil
+}
+
+// A reader closing all the readers it wraps
+type multiCloser struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (m *multiCloser) Close() error {
+	var err error
+	for i := len(m.closers) - 1; i >= 0; i-- {
+		if cerr := m.closers[i].Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// Decompress and extract r until it's not compressed or archived anymore
+func (m *multiCloser) unwrap(r io.Reader) (*bufio.Reader, error) {
+	for {
+		br := bufio.NewReaderSize(r, tarSniffLen)
+		magic, err := br.Peek(tarSniffLen)
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+
+		if isTarArchive(magic) {
+			return m.extractRDB(tar.NewReader(br))
+		}
+
+		d := findDecompressor(magic)
+		if d == nil && bytes.HasPrefix(magic, ZstdMagic) {
+			return nil, ErrUnsupportedCompression
+		}
+		if d == nil {
+			return br, nil
+		}
+
+		dr, err := d(br)
+		if err != nil {
+			return nil, err
+		}
+		m.closers = append(m.closers, dr)
+		r = dr
+	}
+}
+
+// Returns the reader of the first RDB file of a tar archive
+func (m *multiCloser) extractRDB(tr *tar.Reader) (*bufio.Reader, error) {
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil, ErrRDBNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		br, err := m.unwrap(tr)
+		if err == ErrRDBNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		magic, err := br.Peek(5)
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if string(magic) == "REDIS" || string(magic) == "VALKE" {
+			return br, nil
+		}
+	}
+}
+
+func isTarArchive(magic []byte) bool {
+	return len(magic) >= tarSniffLen && bytes.Equal(magic[257:262], []byte("ustar"))
+}
+
+func findDecompressor(magic []byte) Decompressor {
+	decompressorsMu.RLock()
+	defer decompressorsMu.RUnlock()
+
+	for _, d := range decompressors {
+		if bytes.HasPrefix(magic, d.magic) {
+			return d.decompress
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,125 @@
+package rdbtools
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func gzipData(data []byte) []byte {
+	var buffer bytes.Buffer
+	w := gzip.NewWriter(&buffer)
+	w.Write(data)
+	w.Close()
+	return buffer.Bytes()
+}
+
+// Build a tar archive of files, given as name and content pairs
+func tarData(t *testing.T, files ...string) []byte {
+	var buffer bytes.Buffer
+	w := tar.NewWriter(&buffer)
+	for i := 0; i < len(files); i += 2 {
+		hdr := &tar.Header{Name: files[i], Mode: 0644, Size: int64(len(files[i+1])), Typeflag: tar.TypeReg}
+		if err := w.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(w, files[i+1])
+	}
+	w.Close()
+	return buffer.Bytes()
+}
+
+// Write data to a temporary file, open it with Open and parse it
+func openAndParse(t *testing.T, data []byte) ([]StringObject, error) {
+	path := filepath.Join(t.TempDir(), "dump")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	ctx := ParserContext{StringObjectCh: make(chan StringObject)}
+	p := NewParser(ctx)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- p.Parse(f)
+	}()
+
+	var stringObjects []StringObject
+	for v := range ctx.StringObjectCh {
+		stringObjects = append(stringObjects, v)
+	}
+
+	return stringObjects, <-errCh
+}
+
+func TestOpen(t *testing.T) {
+	var rdb bytes.Buffer
+	writeTestRDB(&rdb, "foo", "bar")
+
+	for _, data := range [][]byte{
+		rdb.Bytes(),
+		gzipData(rdb.Bytes()),
+		tarData(t, "README", "not a dump", "dump.rdb", rdb.String()),
+		gzipData(tarData(t, "dump.rdb.gz", string(gzipData(rdb.Bytes())))),
+	} {
+		stringObjects, err := openAndParse(t, data)
+		ok(t, err)
+		equals(t, 1, len(stringObjects))
+		equals(t, "foo", DataToString(stringObjects[0].Key))
+	}
+}
+
+func TestOpenInvalidChecksum(t *testing.T) {
+	var rdb bytes.Buffer
+	writeTestRDB(&rdb, "foo", "bar")
+	data := rdb.Bytes()
+	data[len(data)-1]++
+
+	_, err := openAndParse(t, gzipData(data))
+	equals(t, ErrInvalidChecksum, err)
+}
+
+func TestOpenNoRDBInArchive(t *testing.T) {
+	_, err := openAndParse(t, tarData(t, "README", "not a dump"))
+	equals(t, ErrRDBNotFound, err)
+}
+
+func TestOpenZstdNotRegistered(t *testing.T) {
+	var rdb bytes.Buffer
+	writeTestRDB(&rdb, "foo", "bar")
+
+	_, err := openAndParse(t, append(append([]byte{}, ZstdMagic...), rdb.Bytes()...))
+	equals(t, ErrUnsupportedCompression, err)
+
+	// In an archive
+	_, err = openAndParse(t, tarData(t, "dump.rdb.zst", string(ZstdMagic)+rdb.String()))
+	equals(t, ErrUnsupportedCompression, err)
+}
+
+func TestOpenRegisteredDecompressor(t *testing.T) {
+	// A decompressor which only strips its magic bytes
+	magic := []byte("TEST")
+	RegisterDecompressor(magic, func(r io.Reader) (io.ReadCloser, error) {
+		if _, err := io.ReadFull(r, make([]byte, len(magic))); err != nil {
+			return nil, err
+		}
+		return io.NopCloser(r), nil
+	})
+
+	var rdb bytes.Buffer
+	writeTestRDB(&rdb, "foo", "bar")
+
+	stringObjects, err := openAndParse(t, append(magic, rdb.Bytes()...))
+	ok(t, err)
+	equals(t, 1, len(stringObjects))
+}
@@ -1,27 +1,53 @@
 package rdbtools
 
 import (
+	"bytes"
+	"context"
 	"encoding/binary"
 	"errors"
 	"io"
 	"math"
 	"strconv"
+	"time"
 )
 
 type Parser interface {
 	Parse(r io.Reader) (err error)
+
+	// Parse a RDB file until ctx is done
+	ParseContext(ctx context.Context, r io.Reader) error
 }
 
 // Parser is the main parser for RDB files
 type parser struct {
-	ctx     ParserContext
+	h       Handler
 	r       io.Reader
 	scratch [4]byte
+	header  Header
+
+	channels *channelHandler // The handler of a parser created with NewParser, even while p.h wraps it
+
+	expireAtSnapshotTime bool // See ParserContext.ExpireAtSnapshotTime and WithExpireAtSnapshotTime
+	keyDBMemberExpiries  bool // See ParserContext.KeyDBMemberExpiries and WithKeyDBMemberExpiries
+
+	db             int       // The number of the database being read
+	dbMetadataSent bool      // Whether the metadata of the database being read has been sent
+	ctime          time.Time // The creation time of the RDB file, from the ctime auxiliary field
+
+	// State of the KeyDB extensions
+	lastKey         *KeyObject          // The last key read in the current database
+	mvccTimestamp   uint64              // The MVCC timestamp of the next key
+	subexpireMember interface{}         // The member of the next member expiry time
+	pending         *memberExpiryBuffer // The events of the last key, waiting for its member expiry times
 }
 
 const (
 	// The last version of RDB files
-	RedisRdbVersion = 6
+	RedisRdbVersion = 12
+
+	// The first and last versions of RDB files with the VALKEY magic string
+	ValkeyMinRdbVersion = 80
+	ValkeyRdbVersion    = 80
 )
 
 var (
@@ -34,27 +60,81 @@ var (
 	ErrUnknownValueType              = errors.New("unknown value type")
 	ErrUnknownLengthEncoding         = errors.New("unknown length encoding")
 	ErrUnexpectedPrevLengthEntryByte = errors.New("unexpected prev length entry byte")
+	ErrUnexpectedListpackEncoding    = errors.New("unexpected listpack entry encoding")
+	ErrUnexpectedQuickListContainer  = errors.New("unexpected quicklist node container")
+	ErrInvalidStreamID               = errors.New("invalid stream ID")
+	ErrInvalidStreamListpack         = errors.New("invalid stream listpack")
+	ErrUnknownModuleType             = errors.New("unknown module type")
+	ErrUnexpectedModuleOpcode        = errors.New("unexpected module opcode")
+	ErrInvalidFunctionLibrary        = errors.New("invalid function library")
+	ErrInvalidAOFCommand             = errors.New("invalid AOF command")
+	ErrInvalidAOFManifest            = errors.New("invalid AOF manifest")
+	ErrInvalidDumpPayload            = errors.New("invalid DUMP payload")
+	ErrUnsupportedDumpValue          = errors.New("unsupported DUMP value")
+	ErrInvalidReplicationReply       = errors.New("invalid replication reply")
+	ErrRDBNotFound                   = errors.New("no RDB file found in archive")
+	ErrUnsupportedCompression        = errors.New("unsupported compression, register a decompressor")
+	ErrInvalidLZFData                = errors.New("invalid LZF compressed data")
+	ErrValueNotAvailable             = errors.New("value not available")
 )
 
 // A ParserContext holds the channels used to receive data from the parser
 type ParserContext struct {
-	DbCh                chan int
-	StringObjectCh      chan StringObject
-	ListMetadataCh      chan ListMetadata
-	ListDataCh          chan interface{}
-	SetMetadataCh       chan SetMetadata
-	SetDataCh           chan interface{}
-	HashMetadataCh      chan HashMetadata
-	HashDataCh          chan HashEntry
-	SortedSetMetadataCh chan SortedSetMetadata
-	SortedSetEntriesCh  chan SortedSetEntry
-	endOfFileCh         chan struct{}
+	HeaderCh               chan Header
+	DbCh                   chan int
+	DatabaseMetadataCh     chan DatabaseMetadata
+	AuxFieldCh             chan AuxField
+	StringObjectCh         chan StringObject
+	ListMetadataCh         chan ListMetadata
+	ListDataCh             chan interface{}
+	SetMetadataCh          chan SetMetadata
+	SetDataCh              chan interface{}
+	HashMetadataCh         chan HashMetadata
+	HashDataCh             chan HashEntry
+	SortedSetMetadataCh    chan SortedSetMetadata
+	SortedSetEntriesCh     chan SortedSetEntry
+	StreamMetadataCh       chan StreamMetadata
+	StreamEntriesCh        chan StreamEntry
+	StreamConsumerGroupsCh chan StreamConsumerGroup
+	ModuleObjectCh         chan ModuleObject
+	ModuleAuxCh            chan ModuleAux
+	MemberExpiryCh         chan MemberExpiry
+	FunctionLibraryCh      chan FunctionLibrary
+	SlotInfoCh             chan SlotInfo
+	AOFCommandCh           chan AOFCommand
+	endOfFileCh            chan struct{}
+
+	// Receives the elements of the lists, sets, hashes, sorted sets and streams with their key,
+	// followed by an event marking the end of each collection
+	CollectionEventCh chan CollectionEvent
+
+	// Receives the error stopping the parser, after the other channels are closed.
+	// It must be buffered, with a capacity of at least 1: the error is sent
+	// without blocking, even if nobody reads the channels anymore.
+	ErrCh chan error
+
+	// If true, the keys are evaluated as expired or not against the creation time of the RDB file
+	// (the ctime auxiliary field) instead of the current time, see KeyObject.ExpiryReference
+	ExpireAtSnapshotTime bool
+
+	// If true, the KeyDB member expiry times are set on the entries of their hash or sorted set,
+	// and sent right after their set member. See WithKeyDBMemberExpiries.
+	KeyDBMemberExpiries bool
 }
 
 func (c *ParserContext) closeChannels() {
+	if c.HeaderCh != nil {
+		close(c.HeaderCh)
+	}
 	if c.DbCh != nil {
 		close(c.DbCh)
 	}
+	if c.DatabaseMetadataCh != nil {
+		close(c.DatabaseMetadataCh)
+	}
+	if c.AuxFieldCh != nil {
+		close(c.AuxFieldCh)
+	}
 	if c.StringObjectCh != nil {
 		close(c.StringObjectCh)
 	}
@@ -82,32 +162,169 @@ func (c *ParserContext) closeChannels() {
 	if c.SortedSetEntriesCh != nil {
 		close(c.SortedSetEntriesCh)
 	}
-	close(c.endOfFileCh)
+	if c.StreamMetadataCh != nil {
+		close(c.StreamMetadataCh)
+	}
+	if c.StreamEntriesCh != nil {
+		close(c.StreamEntriesCh)
+	}
+	if c.StreamConsumerGroupsCh != nil {
+		close(c.StreamConsumerGroupsCh)
+	}
+	if c.ModuleObjectCh != nil {
+		close(c.ModuleObjectCh)
+	}
+	if c.ModuleAuxCh != nil {
+		close(c.ModuleAuxCh)
+	}
+	if c.MemberExpiryCh != nil {
+		close(c.MemberExpiryCh)
+	}
+	if c.FunctionLibraryCh != nil {
+		close(c.FunctionLibraryCh)
+	}
+	if c.SlotInfoCh != nil {
+		close(c.SlotInfoCh)
+	}
+	if c.AOFCommandCh != nil {
+		close(c.AOFCommandCh)
+	}
+	if c.CollectionEventCh != nil {
+		close(c.CollectionEventCh)
+	}
+	if c.ErrCh != nil {
+		close(c.ErrCh)
+	}
+	if c.endOfFileCh != nil {
+		close(c.endOfFileCh)
+	}
+}
+
+// Panics if ErrCh can't hold the error stopping the parser
+func (c *ParserContext) checkErrCh() {
+	if c.ErrCh != nil && cap(c.ErrCh) == 0 {
+		panic("rdbtools: ParserContext.ErrCh must be buffered")
+	}
 }
 
 // Invalid returns true if the context is invalid (all channels are nil), false otherwise.
 // This is needed to actually terminate parsing if you use a for-select loop
 func (c *ParserContext) Invalid() bool {
-	return c.DbCh == nil && c.StringObjectCh == nil && c.ListMetadataCh == nil && c.ListDataCh == nil && c.SetMetadataCh == nil && c.SetDataCh == nil && c.HashMetadataCh == nil && c.HashDataCh == nil && c.SortedSetMetadataCh == nil && c.SortedSetEntriesCh == nil
+	return c.HeaderCh == nil && c.DbCh == nil && c.DatabaseMetadataCh == nil && c.AuxFieldCh == nil && c.StringObjectCh == nil && c.ListMetadataCh == nil && c.ListDataCh == nil && c.SetMetadataCh == nil && c.SetDataCh == nil && c.HashMetadataCh == nil && c.HashDataCh == nil && c.SortedSetMetadataCh == nil && c.SortedSetEntriesCh == nil &&
+		c.StreamMetadataCh == nil && c.StreamEntriesCh == nil && c.StreamConsumerGroupsCh == nil &&
+		c.ModuleObjectCh == nil && c.ModuleAuxCh == nil && c.MemberExpiryCh == nil && c.FunctionLibraryCh == nil && c.SlotInfoCh == nil &&
+		c.AOFCommandCh == nil && c.CollectionEventCh == nil && c.ErrCh == nil
 }
 
-// Create a new parser using the provided context
+// Create a new parser using the provided context.
+// It panics if ctx.ErrCh isn't buffered.
 func NewParser(ctx ParserContext) Parser {
+	ctx.checkErrCh()
 	ctx.endOfFileCh = make(chan struct{})
-	return &parser{ctx: ctx}
+	c := newChannelHandler(ctx)
+	return &parser{h: c, channels: c, expireAtSnapshotTime: ctx.ExpireAtSnapshotTime, keyDBMemberExpiries: ctx.KeyDBMemberExpiries}
+}
+
+// A ParserOption configures a parser created with NewHandlerParser or a Reader created with NewReader.
+// ParserContext has fields for the same settings. Not every option applies to a Reader.
+type ParserOption func(p *parser)
+
+// Evaluate the keys as expired or not against the creation time of the RDB file, the ctime
+// auxiliary field, instead of the current time. See ParserContext.ExpireAtSnapshotTime.
+func WithExpireAtSnapshotTime() ParserOption {
+	return func(p *parser) {
+		p.expireAtSnapshotTime = true
+	}
+}
+
+// Set the KeyDB member expiry times on the entries of their hash or sorted set, and send
+// them right after their set member, instead of sending them after the collection.
+//
+// KeyDB saves the member expiry times after their collection, so the entries of each set, hash
+// and sorted set are held in memory until the opcode following the collection is read.
+//
+// It has no effect on a Reader, which reads each value on its own.
+func WithKeyDBMemberExpiries() ParserOption {
+	return func(p *parser) {
+		p.keyDBMemberExpiries = true
+	}
+}
+
+// Create a new parser calling the methods of h
+func NewHandlerParser(h Handler, opts ...ParserOption) Parser {
+	p := &parser{h: h}
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	return p
 }
 
 // Parse a RDB file reading data from the provided reader r
 // Any error occurring while parsing will be returned here
-func (p *parser) Parse(r io.Reader) (err error) {
+func (p *parser) Parse(r io.Reader) error {
+	return p.ParseContext(context.Background(), r)
+}
+
+// Parse a RDB file reading data from the provided reader r, stopping with the error of ctx
+// once it's done. The channels of a ParserContext are closed even if parsing fails,
+// before sending the error on ErrCh.
+//
+// A read blocked on r is only interrupted if r has a SetReadDeadline method, like net.Conn
+// and os.File. Otherwise, ctx is checked before each read.
+// To interrupt it, the read deadline of r is set once ctx is done, and cleared before
+// returning: r has no read deadline anymore, even if it had one before.
+func (p *parser) ParseContext(ctx context.Context, r io.Reader) error {
+	if p.channels != nil {
+		p.channels.cancel = ctx
+	}
+
+	if d, ok := r.(readDeadliner); ok {
+		interrupted := make(chan struct{})
+		stop := context.AfterFunc(ctx, func() {
+			d.SetReadDeadline(time.Now())
+			close(interrupted)
+		})
+		defer func() {
+			if !stop() {
+				<-interrupted
+				d.SetReadDeadline(time.Time{})
+			}
+		}()
+	}
+
+	err := p.parse(contextReader{ctx: ctx, r: r})
+	if err == nil {
+		return p.h.EndRDB()
+	}
+
+	if ctx.Err() != nil {
+		err = ctx.Err()
+	}
+	if p.channels != nil {
+		p.channels.fail(err)
+	}
+
+	return err
+}
+
+// Parse a RDB file without calling EndRDB, which lets
+// the AOF parser send more data after a RDB preamble.
+func (p *parser) parse(r io.Reader) (err error) {
+	// Don't leave p.h wrapped by the events held for their KeyDB member expiry times
+	defer func() {
+		if err != nil && p.pending != nil {
+			p.h, p.pending = p.pending.Handler, nil
+		}
+	}()
+
 	cr := newChecksumReader(r)
 
-	if err = readMagicString(cr); err != nil {
+	if p.header, err = readHeader(cr); err != nil {
 		return err
 	}
 
-	var rdbVersion int
-	if rdbVersion, err = readVersionNumber(cr); err != nil {
+	if err := p.h.StartRDB(p.header); err != nil {
 		return err
 	}
 
@@ -118,109 +335,101 @@ func (p *parser) Parse(r io.Reader) (err error) {
 			break
 		}
 
+		readKeyValuePair := p.readKeyValuePair
+		if p.keyDBMemberExpiries {
+			readKeyValuePair = p.readBufferedKeyValuePair
+		}
+
 		for {
-			if err = p.readKeyValuePair(cr); err != nil && err != errNoMoreKeyValuePair {
+			if err = readKeyValuePair(cr); err != nil && err != errNoMoreKeyValuePair {
 				return err
 			} else if err != nil && err == errNoMoreKeyValuePair {
 				break
 			}
 		}
 
-		if p.scratch[0] == 0xFF {
-			break
-		}
-	}
-
-	// Read the CRC64 checksum with RDB version >= 5
-	if rdbVersion >= 5 {
-		sum := cr.checksum
-
-		var checksum uint64
-		if err := binary.Read(cr, binary.LittleEndian, &checksum); err != nil {
+		if err := p.h.EndDatabase(p.db); err != nil {
 			return err
 		}
 
-		if sum != checksum {
-			return ErrInvalidChecksum
+		if p.scratch[0] == 0xFF {
+			break
 		}
 	}
 
-	p.ctx.closeChannels()
-
-	return nil
-}
-
-func readMagicString(r io.Reader) error {
-	data := make([]byte, 5)
-	read, err := r.Read(data)
-	if err != nil {
-		return err
-	}
-
-	if read != 5 {
-		return ErrInvalidMagicString
-	}
-
-	if string(data) != "REDIS" {
-		return ErrInvalidMagicString
-	}
-
-	return nil
+	return p.readChecksum(cr)
 }
 
-func readVersionNumber(r io.Reader) (int, error) {
-	data := make([]byte, 4)
-	read, err := r.Read(data)
-	if err != nil {
-		return -1, err
+// Read the CRC64 checksum with RDB version >= 5
+func (p *parser) readChecksum(cr *checksumReader) error {
+	if p.header.Version < 5 {
+		return nil
 	}
 
-	if read != 4 {
-		return -1, ErrInvalidRDBVersionNumber
-	}
+	sum := cr.checksum
 
-	val := string(data)
-	ival, err := strconv.Atoi(val)
-	if err != nil {
-		return -1, err
+	var checksum uint64
+	if err := binary.Read(cr, binary.LittleEndian, &checksum); err != nil {
+		return err
 	}
 
-	if ival < 1 || ival > RedisRdbVersion {
-		return -1, ErrInvalidRDBVersionNumber
+	// A zero checksum means the file was written with rdbchecksum disabled
+	if checksum != 0 && sum != checksum {
+		return ErrInvalidChecksum
 	}
 
-	return ival, nil
+	return nil
 }
 
 func (p *parser) readDatabase(r io.Reader) error {
 	// Might have read the 0xFE byte already in the last readKeyValuePair call
 	if p.scratch[0] != 0xFE {
-		_, err := io.ReadFull(r, p.scratch[0:1])
-		if err != nil {
-			return err
-		}
+		for {
+			_, err := io.ReadFull(r, p.scratch[0:1])
+			if err != nil {
+				return err
+			}
 
-		if p.scratch[0] != 0xFE {
-			return errNoMoreDatabases
+			b := p.scratch[0]
+			if b == 0xFE {
+				break
+			}
+
+			handled, err := p.readAuxiliaryOpcode(b, r)
+			if err != nil {
+				return err
+			}
+			if !handled {
+				return errNoMoreDatabases
+			}
 		}
 	}
 
-	var dbNumber uint8
-	if err := binary.Read(r, binary.BigEndian, &dbNumber); err != nil {
+	dbNumber, e, err := p.readLen(r)
+	if err != nil {
 		return err
 	}
+	if e {
+		return ErrUnexpectedEncodedLength
+	}
+
+	p.db = int(dbNumber)
+	p.dbMetadataSent = false
+	p.lastKey = nil
 
-	if p.ctx.DbCh != nil {
-		p.ctx.DbCh <- int(dbNumber)
+	if err := p.h.StartDatabase(p.db); err != nil {
+		return err
 	}
 
 	return nil
 }
 
-func (p *parser) readLen(r io.Reader) (int64, bool, error) {
+// Read a length. If the returned boolean is true, the length is a special encoding
+// and the returned value holds the encoding type instead.
+func (p *parser) readLen(r io.Reader) (uint64, bool, error) {
 	_, err := io.ReadFull(r, p.scratch[0:1])
 	if err != nil {
-		return -1, false, err
+		return 0, false, err
 	}
 
 	b := p.scratch[0]
@@ -228,22 +437,34 @@ func (p *parser) readLen(r io.Reader) (int64, bool, error) {
 	bits := (b & 0xC0) >> 6
 	switch bits {
 	case 0:
-		return int64(b) & 0x3f, false, nil
+		return uint64(b) & 0x3f, false, nil
 	case 1:
 		_, err := io.ReadFull(r, p.scratch[0:1])
 		if err != nil {
-			return -1, false, err
+			return 0, false, err
 		}
-		return int64((int64(b)&0x3f)<<8) | int64(p.scratch[0]), false, nil
+		return (uint64(b)&0x3f)<<8 | uint64(p.scratch[0]), false, nil
 	case 2:
-		var tmp uint32
-		if err := binary.Read(r, binary.BigEndian, &tmp); err != nil {
-			return -1, false, err
-		}
+		switch b {
+		case 0x80: // 32 bit length
+			var tmp uint32
+			if err := binary.Read(r, binary.BigEndian, &tmp); err != nil {
+				return 0, false, err
+			}
 
-		return int64(tmp), false, nil
+			return uint64(tmp), false, nil
+		case 0x81: // 64 bit length
+			var tmp uint64
+			if err := binary.Read(r, binary.BigEndian, &tmp); err != nil {
+				return 0, false, err
+			}
+
+			return tmp, false, nil
+		default:
+			return 0, false, ErrUnknownLengthEncoding
+		}
 	default:
-		return int64(b) & 0x3f, true, nil
+		return uint64(b) & 0x3f, true, nil
 	}
 }
 
@@ -263,7 +484,7 @@ func (p *parser) readDoubleValue(r io.Reader) (float64, error) {
 	case 253:
 		return math.NaN(), nil
 	default:
-		bytes, err := readBytes(r, int64(l))
+		bytes, err := readBytes(r, uint64(l))
 		if err != nil {
 			return 0, err
 		}
@@ -272,14 +493,67 @@ func (p *parser) readDoubleValue(r io.Reader) (float64, error) {
 	}
 }
 
-func readBytes(r io.Reader, length int64) ([]byte, error) {
-	bytes := make([]byte, length)
-	_, err := io.ReadFull(r, bytes)
+// Read a time in milliseconds stored as a little endian 64 bit integer
+func readMillisecondTime(r io.Reader) (time.Time, error) {
+	var ms int64
+	if err := binary.Read(r, binary.LittleEndian, &ms); err != nil {
+		return time.Time{}, err
+	}
+
+	return millisecondsToTime(ms), nil
+}
+
+func millisecondsToTime(ms int64) time.Time {
+	return time.Unix(ms/1000, (ms%1000)*int64(time.Millisecond)).UTC()
+}
+
+func timeToMilliseconds(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+// Read a double stored as a little endian IEEE 754 binary value
+func readBinaryDoubleValue(r io.Reader) (float64, error) {
+	var v float64
+	if err := binary.Read(r, binary.LittleEndian, &v); err != nil {
+		return 0, err
+	}
+
+	return v, nil
+}
+
+// The largest length readBytes allocates upfront
+const readBytesChunkSize = 64 * 1024
+
+// Read length bytes.
+//
+// Large lengths aren't allocated upfront, the buffer grows as the bytes arrive instead.
+// That way a bogus length fails with io.ErrUnexpectedEOF once the data runs out.
+func readBytes(r io.Reader, length uint64) ([]byte, error) {
+	if length <= readBytesChunkSize {
+		data := make([]byte, length)
+		_, err := io.ReadFull(r, data)
+		if err != nil {
+			return nil, err
+		}
+
+		return data, nil
+	}
+
+	n := int64(math.MaxInt64)
+	if length < math.MaxInt64 {
+		n = int64(length)
+	}
+
+	var buf bytes.Buffer
+	written, err := io.CopyN(&buf, r, n)
+	if err == io.EOF && written > 0 {
+		err = io.ErrUnexpectedEOF
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	return bytes, nil
+	return buf.Bytes(), nil
 }
 
 func (p *parser) readLZFString(r io.Reader) ([]byte, error) {
@@ -298,7 +572,7 @@ func (p *parser) readLZFString(r io.Reader) ([]byte, error) {
 		return nil, err
 	}
 
-	return lzfDecompress(cdata, ulen), nil
+	return lzfDecompress(cdata, ulen)
 }
 
 func (p *parser) readString(r io.Reader) (interface{}, error) {
@@ -346,50 +620,152 @@ func (p *parser) readString(r io.Reader) (interface{}, error) {
 	return bytes, nil
 }
 
-func (p *parser) readKeyValuePair(r io.Reader) error {
-	_, err := io.ReadFull(r, p.scratch[0:1])
+// Read a string holding a zip list, a listpack or another structure, which can't be encoded as an integer
+func (p *parser) readBytesString(r io.Reader) ([]byte, error) {
+	data, err := p.readString(r)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	if p.scratch[0] == 0xFE || p.scratch[0] == 0xFF {
-		return errNoMoreKeyValuePair
+	b, ok := data.([]byte)
+	if !ok {
+		return nil, ErrUnexpectedEncodedLength
 	}
 
-	b := p.scratch[0]
+	return b, nil
+}
 
-	// Read expiry time in seconds
-	var expiryTime int64 = -1
-	if b == 0xFD {
-		var tmp uint32
-		if err := binary.Read(r, binary.LittleEndian, &tmp); err != nil {
-			return err
-		}
-		expiryTime = int64(int64(tmp) * 1000)
+// Read an opcode which is not tied to a key and can appear before, between or after databases.
+// Returns false if b is not such an opcode.
+func (p *parser) readAuxiliaryOpcode(b byte, r io.Reader) (bool, error) {
+	switch b {
+	case 0xFA: // Auxiliary field
+		return true, p.readAuxField(r)
+	case 0xF5: // Function library
+		return true, p.readFunction2(r)
+	case 0xF6: // Function library, Redis 7.0 release candidates format
+		return true, p.readFunctionPreGA(r)
+	case 0xF4: // Slot info
+		return true, p.readSlotInfo(r)
+	case 0xF7: // Module auxiliary data
+		return true, p.readModuleAux(r)
+	default:
+		return false, nil
 	}
+}
 
-	// Read expiry time in milliseconds
-	if b == 0xFC {
-		if err := binary.Read(r, binary.LittleEndian, &expiryTime); err != nil {
-			return err
-		}
+func (p *parser) readKeyValuePair(r io.Reader) error {
+	key, b, err := p.readKey(r)
+	if err != nil {
+		return err
 	}
 
-	// If the byte was a expiry time flag, we need to reread a byte
-	if b == 0xFD || b == 0xFC {
+	return p.readValue(key, b, r)
+}
+
+// Read the next key of the database and the type of its value
+func (p *parser) readKey(r io.Reader) (KeyObject, byte, error) {
+	var b byte
+	for {
 		_, err := io.ReadFull(r, p.scratch[0:1])
+		if err != nil {
+			return KeyObject{}, 0, err
+		}
+
 		b = p.scratch[0]
+
+		// The auxiliary fields can be member expiry times of the last key
+		if b != 0xFA {
+			if err := p.flushPending(); err != nil {
+				return KeyObject{}, 0, err
+			}
+		}
+
+		// The RESIZEDB opcode, if present, directly follows the SELECTDB opcode
+		if !p.dbMetadataSent {
+			if err := p.readDatabaseMetadata(b, r); err != nil {
+				return KeyObject{}, 0, err
+			}
+			if b == 0xFB {
+				continue
+			}
+		}
+
+		if b == 0xFE || b == 0xFF {
+			return KeyObject{}, 0, errNoMoreKeyValuePair
+		}
+
+		handled, err := p.readAuxiliaryOpcode(b, r)
 		if err != nil {
-			return err
+			return KeyObject{}, 0, err
+		}
+		if !handled {
+			break
 		}
 	}
 
+	// The expiry time, LRU idle time and LFU frequency opcodes can precede the value type
+	var expiryTime int64 = -1
+	var idle uint64
+	var freq byte
+	var hasIdle, hasFreq bool
+	for b == 0xFD || b == 0xFC || b == 0xF8 || b == 0xF9 {
+		switch b {
+		case 0xFD: // Expiry time in seconds
+			var tmp uint32
+			if err := binary.Read(r, binary.LittleEndian, &tmp); err != nil {
+				return KeyObject{}, 0, err
+			}
+			expiryTime = int64(int64(tmp) * 1000)
+		case 0xFC: // Expiry time in milliseconds
+			if err := binary.Read(r, binary.LittleEndian, &expiryTime); err != nil {
+				return KeyObject{}, 0, err
+			}
+		case 0xF8: // LRU idle time in seconds
+			l, e, err := p.readLen(r)
+			if err != nil {
+				return KeyObject{}, 0, err
+			}
+			if e {
+				return KeyObject{}, 0, ErrUnexpectedEncodedLength
+			}
+			idle, hasIdle = l, true
+		case 0xF9: // LFU frequency
+			if _, err := io.ReadFull(r, p.scratch[0:1]); err != nil {
+				return KeyObject{}, 0, err
+			}
+			freq, hasFreq = p.scratch[0], true
+		}
+
+		if _, err := io.ReadFull(r, p.scratch[0:1]); err != nil {
+			return KeyObject{}, 0, err
+		}
+		b = p.scratch[0]
+	}
+
 	keyStr, err := p.readString(r)
 	if err != nil {
-		return err
+		return KeyObject{}, 0, err
 	}
 
 	key := NewKeyObject(keyStr, expiryTime)
+	key.IdleSeconds, key.HasIdleSeconds = idle, hasIdle
+	key.LFUCounter, key.HasLFUCounter = freq, hasFreq
+	if p.expireAtSnapshotTime {
+		key.ExpiryReference = p.ctime
+	}
+	key.DB = p.db
+	key.MVCCTimestamp, p.mvccTimestamp = p.mvccTimestamp, 0
+	p.lastKey = &key
+
+	return key, b, nil
+}
+
+// Read a value of type b and send it with key
+func (p *parser) readValue(key KeyObject, b byte, r io.Reader) error {
+	if err := p.header.checkValueType(b); err != nil {
+		return err
+	}
 
 	switch b {
 	case 0: // String encoding
@@ -398,8 +774,8 @@ func (p *parser) readKeyValuePair(r io.Reader) error {
 			return err
 		}
 
-		if p.ctx.StringObjectCh != nil {
-			p.ctx.StringObjectCh <- StringObject{Key: key, Value: value}
+		if err := p.h.String(StringObject{Key: key, Value: value}); err != nil {
+			return err
 		}
 	case 1: // List encoding
 		if err := p.readList(key, r); err != nil {
@@ -417,6 +793,18 @@ func (p *parser) readKeyValuePair(r io.Reader) error {
 		if err := p.readHashMap(key, r); err != nil {
 			return err
 		}
+	case 5: // Sorted set with binary scores encoding
+		if err := p.readSortedSet2(key, r); err != nil {
+			return err
+		}
+	case 6: // Module encoding (Redis < 5.0)
+		if err := p.readModule(key, r, false); err != nil {
+			return err
+		}
+	case 7: // Module encoding with opcodes
+		if err := p.readModule(key, r, true); err != nil {
+			return err
+		}
 	case 9: // Zipmap encoding
 		if err := p.readZipMap(key, r); err != nil {
 			return err
@@ -437,9 +825,93 @@ func (p *parser) readKeyValuePair(r io.Reader) error {
 		if err := p.readHashMapInZipList(key, r); err != nil {
 			return err
 		}
+	case 14: // List in quick list encoding
+		if err := p.readListInQuickList(key, r); err != nil {
+			return err
+		}
+	case 15: // Stream in listpacks encoding
+		if err := p.readStream(key, r, 1); err != nil {
+			return err
+		}
+	case 16: // Hash map in listpack encoding
+		if err := p.readHashMapInListpack(key, r); err != nil {
+			return err
+		}
+	case 17: // Sorted set in listpack encoding
+		if err := p.readSortedSetInListpack(key, r); err != nil {
+			return err
+		}
+	case 18: // List in quick list 2 encoding
+		if err := p.readListInQuickList2(key, r); err != nil {
+			return err
+		}
+	case 19: // Stream in listpacks encoding, version 2
+		if err := p.readStream(key, r, 2); err != nil {
+			return err
+		}
+	case 20: // Set in listpack encoding
+		if err := p.readSetInListpack(key, r); err != nil {
+			return err
+		}
+	case 21: // Stream in listpacks encoding, version 3
+		if err := p.readStream(key, r, 3); err != nil {
+			return err
+		}
+	case 22: // Hash map with field expiration times, Redis 7.4 release candidates or Valkey format
+		if p.header.Flavour == FlavourValkey {
+			if err := p.readValkeyHashMap(key, r); err != nil {
+				return err
+			}
+		} else if err := p.readHashMapWithMetadata(key, r, true); err != nil {
+			return err
+		}
+	case 23: // Hash map with field expiration times in listpack encoding, Redis 7.4 release candidates format
+		if err := p.readHashMapInListpackEx(key, r, true); err != nil {
+			return err
+		}
+	case 24: // Hash map with field expiration times
+		if err := p.readHashMapWithMetadata(key, r, false); err != nil {
+			return err
+		}
+	case 25: // Hash map with field expiration times in listpack encoding
+		if err := p.readHashMapInListpackEx(key, r, false); err != nil {
+			return err
+		}
 	default:
 		return ErrUnknownValueType
 	}
 
+	switch b {
+	case 1, 10, 14, 18:
+		return p.h.EndList(key)
+	case 2, 11, 20:
+		return p.h.EndSet(key)
+	case 3, 5, 12, 17:
+		return p.h.EndSortedSet(key)
+	case 4, 9, 13, 16, 22, 23, 24, 25:
+		return p.h.EndHash(key)
+	case 15, 19, 21:
+		return p.h.EndStream(key)
+	}
+
 	return nil
 }
+
+// A reader whose blocked reads can be interrupted, such as a net.Conn
+type readDeadliner interface {
+	SetReadDeadline(t time.Time) error
+}
+
+// A reader failing once its context is done
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	return c.r.Read(p)
+}
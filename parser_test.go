@@ -3,9 +3,12 @@ package rdbtools
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/binary"
 	"io"
 	"math"
+	"net"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -14,90 +17,10 @@ import (
 func mustParse(t *testing.T, p Parser, ctx ParserContext, r io.Reader) {
 	err := p.Parse(r)
 	if err != nil {
-		ctx.closeChannels()
-		t.Fatalf("Error while parsing; err=%s", err)
+		t.Errorf("Error while parsing; err=%s", err)
 	}
 }
 
-func TestReadMagicString(t *testing.T) {
-	var buffer bytes.Buffer
-
-	br := bufio.NewWriter(&buffer)
-	br.WriteString("REDIS")
-	br.Flush()
-
-	err := readMagicString(bufio.NewReader(&buffer))
-	ok(t, err)
-
-	// No data
-	buffer.Reset()
-
-	err = readMagicString(bufio.NewReader(&buffer))
-	equals(t, io.EOF, err)
-
-	// Not enough data
-	buffer.Reset()
-	br.WriteString("FOO")
-	br.Flush()
-
-	err = readMagicString(bufio.NewReader(&buffer))
-	equals(t, ErrInvalidMagicString, err)
-
-	// Invalid data
-	buffer.Reset()
-	br.WriteString("FOOBA")
-	br.Flush()
-
-	err = readMagicString(bufio.NewReader(&buffer))
-	equals(t, ErrInvalidMagicString, err)
-}
-
-func TestReadVersionNumber(t *testing.T) {
-	var buffer bytes.Buffer
-
-	br := bufio.NewWriter(&buffer)
-	br.WriteString("0006")
-	br.Flush()
-
-	v, err := readVersionNumber(bufio.NewReader(&buffer))
-	ok(t, err)
-	equals(t, 6, v)
-
-	// No data
-	buffer.Reset()
-
-	v, err = readVersionNumber(bufio.NewReader(&buffer))
-	equals(t, io.EOF, err)
-	equals(t, -1, v)
-
-	// Not enough data
-	buffer.Reset()
-	br.WriteString("FOO")
-	br.Flush()
-
-	v, err = readVersionNumber(bufio.NewReader(&buffer))
-	equals(t, ErrInvalidRDBVersionNumber, err)
-	equals(t, -1, v)
-
-	// Not a number
-	buffer.Reset()
-	br.WriteString("foob")
-	br.Flush()
-
-	v, err = readVersionNumber(bufio.NewReader(&buffer))
-	equals(t, "strconv.ParseInt: parsing \"foob\": invalid syntax", err.Error())
-	equals(t, -1, v)
-
-	// Wrong version number
-	buffer.Reset()
-	br.WriteString("0010")
-	br.Flush()
-
-	v, err = readVersionNumber(bufio.NewReader(&buffer))
-	equals(t, ErrInvalidRDBVersionNumber, err)
-	equals(t, -1, v)
-}
-
 func TestReadDatabase(t *testing.T) {
 	var buffer bytes.Buffer
 
@@ -107,7 +30,7 @@ func TestReadDatabase(t *testing.T) {
 	br.Flush()
 
 	ctx := ParserContext{DbCh: make(chan int)}
-	p := &parser{ctx: ctx}
+	p := &parser{h: newChannelHandler(ctx)}
 
 	go func() {
 		db := <-ctx.DbCh
@@ -122,7 +45,7 @@ func TestReadDatabaseNoData(t *testing.T) {
 	var buffer bytes.Buffer
 
 	ctx := ParserContext{DbCh: make(chan int)}
-	p := &parser{ctx: ctx}
+	p := &parser{h: newChannelHandler(ctx)}
 
 	err := p.readDatabase(bufio.NewReader(&buffer))
 	equals(t, io.EOF, err)
@@ -136,7 +59,7 @@ func TestReadDatabaseNoMoreDatabase(t *testing.T) {
 	br.Flush()
 
 	ctx := ParserContext{DbCh: make(chan int)}
-	p := &parser{ctx: ctx}
+	p := &parser{h: newChannelHandler(ctx)}
 
 	err := p.readDatabase(bufio.NewReader(&buffer))
 	equals(t, errNoMoreDatabases, err)
@@ -150,7 +73,7 @@ func TestReadDatabaseNoDbNumber(t *testing.T) {
 	br.Flush()
 
 	ctx := ParserContext{DbCh: make(chan int)}
-	p := &parser{ctx: ctx}
+	p := &parser{h: newChannelHandler(ctx)}
 
 	err := p.readDatabase(bufio.NewReader(&buffer))
 	equals(t, io.EOF, err)
@@ -168,7 +91,7 @@ func TestReadLen(t *testing.T) {
 
 	l, e, err := p.readLen(bufio.NewReader(&buffer))
 	ok(t, err)
-	equals(t, int64(1), l)
+	equals(t, uint64(1), l)
 	equals(t, false, e)
 
 	// 14 bits encoding
@@ -179,18 +102,29 @@ func TestReadLen(t *testing.T) {
 
 	l, e, err = p.readLen(bufio.NewReader(&buffer))
 	ok(t, err)
-	equals(t, int64(257), l)
+	equals(t, uint64(257), l)
 	equals(t, false, e)
 
 	// 32 bit encoding
 	buffer.Reset()
-	br.WriteByte(0xB0)
-	binary.Write(br, binary.BigEndian, int32(1))
+	br.WriteByte(0x80)
+	binary.Write(br, binary.BigEndian, uint32(1<<32-1))
+	br.Flush()
+
+	l, e, err = p.readLen(bufio.NewReader(&buffer))
+	ok(t, err)
+	equals(t, uint64(1<<32-1), l)
+	equals(t, false, e)
+
+	// 64 bit encoding
+	buffer.Reset()
+	br.WriteByte(0x81)
+	binary.Write(br, binary.BigEndian, uint64(1<<64-1))
 	br.Flush()
 
 	l, e, err = p.readLen(bufio.NewReader(&buffer))
 	ok(t, err)
-	equals(t, int64(1), l)
+	equals(t, uint64(1<<64-1), l)
 	equals(t, false, e)
 
 	// special encoding
@@ -200,7 +134,7 @@ func TestReadLen(t *testing.T) {
 
 	l, e, err = p.readLen(bufio.NewReader(&buffer))
 	ok(t, err)
-	equals(t, int64(17), l)
+	equals(t, uint64(17), l)
 	equals(t, true, e)
 
 	// 14 bits encoding - no additional byte
@@ -209,7 +143,7 @@ func TestReadLen(t *testing.T) {
 	br.Flush()
 
 	l, e, err = p.readLen(bufio.NewReader(&buffer))
-	equals(t, int64(-1), l)
+	equals(t, uint64(0), l)
 	equals(t, false, e)
 	equals(t, io.EOF, err)
 
@@ -219,9 +153,30 @@ func TestReadLen(t *testing.T) {
 	br.Flush()
 
 	l, e, err = p.readLen(bufio.NewReader(&buffer))
-	equals(t, int64(-1), l)
+	equals(t, uint64(0), l)
 	equals(t, false, e)
 	equals(t, io.EOF, err)
+
+	// 64 bits encoding - no additional data
+	buffer.Reset()
+	br.WriteByte(0x81)
+	br.Write([]byte{0, 0, 0, 0})
+	br.Flush()
+
+	l, e, err = p.readLen(bufio.NewReader(&buffer))
+	equals(t, uint64(0), l)
+	equals(t, false, e)
+	equals(t, io.ErrUnexpectedEOF, err)
+
+	// unknown encoding
+	buffer.Reset()
+	br.WriteByte(0xB0)
+	br.Flush()
+
+	l, e, err = p.readLen(bufio.NewReader(&buffer))
+	equals(t, uint64(0), l)
+	equals(t, false, e)
+	equals(t, ErrUnknownLengthEncoding, err)
 }
 
 func TestReadDoubleValue(t *testing.T) {
@@ -347,6 +302,41 @@ func TestReadString(t *testing.T) {
 	ok(t, err)
 	equals(t, "a", DataToString(v))
 
+	// Length prefixed string with a 64 bit length
+	buffer.Reset()
+	br.WriteByte(0x81)
+	binary.Write(br, binary.BigEndian, uint64(3))
+	br.WriteString("abc")
+	br.Flush()
+
+	v, err = p.readString(bufio.NewReader(&buffer))
+	ok(t, err)
+	equals(t, "abc", DataToString(v))
+
+	// Length prefixed string larger than what's allocated upfront
+	buffer.Reset()
+	br.WriteByte(0x80)
+	binary.Write(br, binary.BigEndian, uint32(readBytesChunkSize+1))
+	br.WriteString(strings.Repeat("a", readBytesChunkSize+1))
+	br.Flush()
+
+	v, err = p.readString(bufio.NewReader(&buffer))
+	ok(t, err)
+	equals(t, strings.Repeat("a", readBytesChunkSize+1), DataToString(v))
+
+	// Length prefixed string with a bogus 64 bit length
+	for _, l := range []uint64{math.MaxInt64 + 1, math.MaxUint64} {
+		buffer.Reset()
+		br.WriteByte(0x81)
+		binary.Write(br, binary.BigEndian, l)
+		br.WriteString("abc")
+		br.Flush()
+
+		v, err = p.readString(bufio.NewReader(&buffer))
+		equals(t, nil, v)
+		equals(t, io.ErrUnexpectedEOF, err)
+	}
+
 	// Int8 encoding
 	buffer.Reset()
 	br.WriteByte(0xC0)
@@ -442,7 +432,7 @@ func TestReadKeyValuePairErrors(t *testing.T) {
 
 	br := bufio.NewWriter(&buffer)
 
-	p := &parser{}
+	p := &parser{h: NopHandler{}}
 
 	err := p.readKeyValuePair(bufio.NewReader(&buffer))
 	equals(t, io.EOF, err)
@@ -488,6 +478,40 @@ func TestReadKeyValuePairErrors(t *testing.T) {
 	err = p.readKeyValuePair(bufio.NewReader(&buffer))
 	equals(t, io.EOF, err)
 
+	// Idle time byte but no data
+	buffer.Reset()
+	br.WriteByte(0xF8)
+	br.Flush()
+
+	err = p.readKeyValuePair(bufio.NewReader(&buffer))
+	equals(t, io.EOF, err)
+
+	// Idle time byte with an encoded length
+	buffer.Reset()
+	br.WriteByte(0xF8)
+	br.WriteByte(0xC0)
+	br.Flush()
+
+	err = p.readKeyValuePair(bufio.NewReader(&buffer))
+	equals(t, ErrUnexpectedEncodedLength, err)
+
+	// Frequency byte but no data
+	buffer.Reset()
+	br.WriteByte(0xF9)
+	br.Flush()
+
+	err = p.readKeyValuePair(bufio.NewReader(&buffer))
+	equals(t, io.EOF, err)
+
+	// Frequency byte but no value type byte
+	buffer.Reset()
+	br.WriteByte(0xF9)
+	br.WriteByte(5)
+	br.Flush()
+
+	err = p.readKeyValuePair(bufio.NewReader(&buffer))
+	equals(t, io.EOF, err)
+
 	// No key data
 	buffer.Reset()
 	br.WriteByte(0)
@@ -503,7 +527,7 @@ func TestReadKeyValuePairStringEncoding(t *testing.T) {
 	br := bufio.NewWriter(&buffer)
 
 	ctx := ParserContext{StringObjectCh: make(chan StringObject)}
-	p := &parser{ctx: ctx}
+	p := &parser{h: newChannelHandler(ctx)}
 
 	go func() {
 		v := <-ctx.StringObjectCh
@@ -539,7 +563,7 @@ func TestReadKeyValuePairSecondExpiry(t *testing.T) {
 	br := bufio.NewWriter(&buffer)
 
 	ctx := ParserContext{StringObjectCh: make(chan StringObject)}
-	p := &parser{ctx: ctx}
+	p := &parser{h: newChannelHandler(ctx)}
 
 	etime := time.Date(2100, time.January, 1, 0, 0, 0, 0, time.UTC)
 
@@ -571,7 +595,7 @@ func TestReadKeyValuePairMillisecondExpiry(t *testing.T) {
 	br := bufio.NewWriter(&buffer)
 
 	ctx := ParserContext{StringObjectCh: make(chan StringObject)}
-	p := &parser{ctx: ctx}
+	p := &parser{h: newChannelHandler(ctx)}
 
 	etime := time.Date(2100, time.January, 1, 0, 0, 0, 0, time.UTC)
 
@@ -597,6 +621,74 @@ func TestReadKeyValuePairMillisecondExpiry(t *testing.T) {
 	ok(t, err)
 }
 
+func TestReadKeyValuePairIdle(t *testing.T) {
+	var buffer bytes.Buffer
+
+	br := bufio.NewWriter(&buffer)
+
+	ctx := ParserContext{StringObjectCh: make(chan StringObject)}
+	p := &parser{h: newChannelHandler(ctx)}
+
+	etime := time.Date(2100, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	df := func() {
+		v := <-ctx.StringObjectCh
+		equals(t, "a", DataToString(v.Key.Key))
+		equals(t, "2100-01-01 00:00:00 +0000 UTC", v.Key.ExpiryTime.UTC().String())
+		equals(t, true, v.Key.HasIdleSeconds)
+		equals(t, uint64(3600), v.Key.IdleSeconds)
+		equals(t, false, v.Key.HasLFUCounter)
+		equals(t, "foobar", DataToString(v.Value))
+	}
+
+	br.WriteByte(0xFC) // expiry in milliseconds
+	binary.Write(br, binary.LittleEndian, uint64(etime.Unix()*1000))
+	br.WriteByte(0xF8) // idle time
+	br.Write([]byte{0x4E, 0x10})
+	br.WriteByte(0)
+	br.Write([]byte{1, 'a'})
+	br.WriteByte(6)
+	br.WriteString("foobar")
+	br.Flush()
+
+	go df()
+
+	err := p.readKeyValuePair(bufio.NewReader(&buffer))
+	ok(t, err)
+}
+
+func TestReadKeyValuePairFreq(t *testing.T) {
+	var buffer bytes.Buffer
+
+	br := bufio.NewWriter(&buffer)
+
+	ctx := ParserContext{StringObjectCh: make(chan StringObject)}
+	p := &parser{h: newChannelHandler(ctx)}
+
+	df := func() {
+		v := <-ctx.StringObjectCh
+		equals(t, "a", DataToString(v.Key.Key))
+		equals(t, true, v.Key.ExpiryTime.IsZero())
+		equals(t, false, v.Key.HasIdleSeconds)
+		equals(t, true, v.Key.HasLFUCounter)
+		equals(t, uint8(5), v.Key.LFUCounter)
+		equals(t, "foobar", DataToString(v.Value))
+	}
+
+	br.WriteByte(0xF9) // frequency
+	br.WriteByte(5)
+	br.WriteByte(0)
+	br.Write([]byte{1, 'a'})
+	br.WriteByte(6)
+	br.WriteString("foobar")
+	br.Flush()
+
+	go df()
+
+	err := p.readKeyValuePair(bufio.NewReader(&buffer))
+	ok(t, err)
+}
+
 func TestReadKeyValuePairListEncoding(t *testing.T) {
 	var buffer bytes.Buffer
 
@@ -606,7 +698,7 @@ func TestReadKeyValuePairListEncoding(t *testing.T) {
 		ListMetadataCh: make(chan ListMetadata),
 		ListDataCh:     make(chan interface{}),
 	}
-	p := &parser{ctx: ctx}
+	p := &parser{h: newChannelHandler(ctx)}
 
 	mf := func() {
 		l := <-ctx.ListMetadataCh
@@ -658,7 +750,7 @@ func TestReadKeyValuePairSetEncoding(t *testing.T) {
 		SetMetadataCh: make(chan SetMetadata),
 		SetDataCh:     make(chan interface{}),
 	}
-	p := &parser{ctx: ctx}
+	p := &parser{h: newChannelHandler(ctx)}
 
 	mf := func() {
 		l := <-ctx.SetMetadataCh
@@ -710,7 +802,7 @@ func TestReadKeyValuePairSortedSetEncoding(t *testing.T) {
 		SortedSetMetadataCh: make(chan SortedSetMetadata),
 		SortedSetEntriesCh:  make(chan SortedSetEntry),
 	}
-	p := &parser{ctx: ctx}
+	p := &parser{h: newChannelHandler(ctx)}
 
 	mf := func() {
 		l := <-ctx.SortedSetMetadataCh
@@ -767,7 +859,7 @@ func TestReadKeyValuePairHashMapEncoding(t *testing.T) {
 		HashMetadataCh: make(chan HashMetadata),
 		HashDataCh:     make(chan HashEntry),
 	}
-	p := &parser{ctx: ctx}
+	p := &parser{h: newChannelHandler(ctx)}
 
 	mf := func() {
 		l := <-ctx.HashMetadataCh
@@ -823,7 +915,7 @@ func TestReadKeyValuePairZipMapEncoding(t *testing.T) {
 		HashMetadataCh: make(chan HashMetadata),
 		HashDataCh:     make(chan HashEntry),
 	}
-	p := &parser{ctx: ctx}
+	p := &parser{h: newChannelHandler(ctx)}
 
 	mf := func() {
 		l := <-ctx.HashMetadataCh
@@ -886,7 +978,7 @@ func TestReadKeyValuePairZipListEncoding(t *testing.T) {
 		ListMetadataCh: make(chan ListMetadata),
 		ListDataCh:     make(chan interface{}),
 	}
-	p := &parser{ctx: ctx}
+	p := &parser{h: newChannelHandler(ctx)}
 
 	mf := func() {
 		l := <-ctx.ListMetadataCh
@@ -946,7 +1038,7 @@ func TestReadKeyValuePairIntSetEncoding(t *testing.T) {
 		SetMetadataCh: make(chan SetMetadata),
 		SetDataCh:     make(chan interface{}),
 	}
-	p := &parser{ctx: ctx}
+	p := &parser{h: newChannelHandler(ctx)}
 
 	mf := func() {
 		l := <-ctx.SetMetadataCh
@@ -1000,7 +1092,7 @@ func TestReadKeyValuePairSortedSetInZipListEncoding(t *testing.T) {
 		SortedSetMetadataCh: make(chan SortedSetMetadata),
 		SortedSetEntriesCh:  make(chan SortedSetEntry),
 	}
-	p := &parser{ctx: ctx}
+	p := &parser{h: newChannelHandler(ctx)}
 
 	mf := func() {
 		l := <-ctx.SortedSetMetadataCh
@@ -1067,7 +1159,7 @@ func TestReadKeyValuePairHashMapInZipListEncoding(t *testing.T) {
 		HashMetadataCh: make(chan HashMetadata),
 		HashDataCh:     make(chan HashEntry),
 	}
-	p := &parser{ctx: ctx}
+	p := &parser{h: newChannelHandler(ctx)}
 
 	mf := func() {
 		l := <-ctx.HashMetadataCh
@@ -1125,71 +1217,349 @@ func TestReadKeyValuePairHashMapInZipListEncoding(t *testing.T) {
 	equals(t, io.EOF, err)
 }
 
-func TestReadKeyValuePairUnknownValueType(t *testing.T) {
+func TestReadKeyValuePairQuickListEncoding(t *testing.T) {
 	var buffer bytes.Buffer
 
 	br := bufio.NewWriter(&buffer)
 
-	br.WriteByte(0xF0)
+	ctx := ParserContext{
+		ListMetadataCh: make(chan ListMetadata),
+		ListDataCh:     make(chan interface{}),
+	}
+	p := &parser{h: newChannelHandler(ctx)}
+
+	go func() {
+		l := <-ctx.ListMetadataCh
+		equals(t, "a", DataToString(l.Key))
+		equals(t, int64(1), l.Len)
+
+		v := <-ctx.ListDataCh
+		equals(t, "a", DataToString(v))
+	}()
+
+	br.WriteByte(14) // quick list encoding
+	br.WriteByte(1)
+	br.WriteByte('a')
+	br.WriteByte(1) // number of zip lists
+	br.WriteByte(13)
+	binary.Write(br, binary.LittleEndian, int32(0))
+	binary.Write(br, binary.LittleEndian, int32(0))
+	binary.Write(br, binary.LittleEndian, int16(1))
+	br.WriteByte(0)
 	br.WriteByte(1)
 	br.WriteByte('a')
 	br.Flush()
 
-	p := &parser{}
 	err := p.readKeyValuePair(bufio.NewReader(&buffer))
-	equals(t, ErrUnknownValueType, err)
+	ok(t, err)
+
+	// No quick list data
+	buffer.Reset()
+	br.WriteByte(14) // quick list encoding
+	br.WriteByte(1)
+	br.WriteByte('a')
+	br.Flush()
+
+	err = p.readKeyValuePair(bufio.NewReader(&buffer))
+	equals(t, io.EOF, err)
 }
 
-func TestParse(t *testing.T) {
+func TestReadKeyValuePairQuickList2Encoding(t *testing.T) {
 	var buffer bytes.Buffer
 
+	br := bufio.NewWriter(&buffer)
+
 	ctx := ParserContext{
-		DbCh:           make(chan int),
-		StringObjectCh: make(chan StringObject),
-		endOfFileCh:    make(chan struct{}),
+		ListMetadataCh: make(chan ListMetadata),
+		ListDataCh:     make(chan interface{}),
 	}
-	p := &parser{ctx: ctx}
+	p := &parser{h: newChannelHandler(ctx)}
 
-	br := bufio.NewWriter(&buffer)
-	br.WriteString("REDIS")  // magic string
-	br.WriteString("0004")   // RDB version TODO use version >= 5 and handle checksum
-	br.WriteByte(0xFE)       // next database byte
-	br.WriteByte(0)          // database number
-	br.WriteByte(0)          // string
-	br.WriteByte(1)          // key len
-	br.WriteByte('a')        // key data
-	br.WriteByte(6)          // string len
-	br.WriteString("foobar") // string data
-	br.WriteByte(0xFF)       // end of file
+	go func() {
+		l := <-ctx.ListMetadataCh
+		equals(t, "a", DataToString(l.Key))
+		equals(t, int64(1), l.Len)
+
+		v := <-ctx.ListDataCh
+		equals(t, "a", DataToString(v))
+	}()
+
+	lp := newListpack("a")
+	br.WriteByte(18) // quick list 2 encoding
+	br.WriteByte(1)
+	br.WriteByte('a')
+	br.WriteByte(1) // number of nodes
+	br.WriteByte(2) // packed node
+	br.WriteByte(byte(len(lp)))
+	br.Write(lp)
 	br.Flush()
 
-	go mustParse(t, p, ctx, bufio.NewReader(&buffer))
+	err := p.readKeyValuePair(bufio.NewReader(&buffer))
+	ok(t, err)
 
-	stop := false
-	for !stop {
-		select {
-		case v, ok := <-ctx.StringObjectCh:
-			if !ok {
-				ctx.StringObjectCh = nil
-				break
-			}
-			equals(t, "a", DataToString(v.Key.Key))
-			equals(t, "foobar", DataToString(v.Value))
-		case v, ok := <-ctx.DbCh:
-			if !ok {
-				ctx.DbCh = nil
-				break
-			}
-			equals(t, int(0), v)
-		}
+	// No quick list data
+	buffer.Reset()
+	br.WriteByte(18) // quick list 2 encoding
+	br.WriteByte(1)
+	br.WriteByte('a')
+	br.Flush()
 
-		if ctx.Invalid() {
-			break
-		}
-	}
+	err = p.readKeyValuePair(bufio.NewReader(&buffer))
+	equals(t, io.EOF, err)
 }
 
-func TestParseAllTypes(t *testing.T) {
+func TestReadKeyValuePairListpackEncodings(t *testing.T) {
+	var buffer bytes.Buffer
+
+	br := bufio.NewWriter(&buffer)
+
+	ctx := ParserContext{
+		SetMetadataCh:       make(chan SetMetadata),
+		SetDataCh:           make(chan interface{}),
+		SortedSetMetadataCh: make(chan SortedSetMetadata),
+		SortedSetEntriesCh:  make(chan SortedSetEntry),
+		HashMetadataCh:      make(chan HashMetadata),
+		HashDataCh:          make(chan HashEntry),
+	}
+	p := &parser{h: newChannelHandler(ctx)}
+
+	go func() {
+		h := <-ctx.HashMetadataCh
+		equals(t, "h", DataToString(h.Key))
+		equals(t, int64(1), h.Len)
+		he := <-ctx.HashDataCh
+		equals(t, "k", DataToString(he.Key))
+		equals(t, "v", DataToString(he.Value))
+
+		z := <-ctx.SortedSetMetadataCh
+		equals(t, "z", DataToString(z.Key))
+		equals(t, int64(1), z.Len)
+		ze := <-ctx.SortedSetEntriesCh
+		equals(t, "m", DataToString(ze.Value))
+		equals(t, 2.0, ze.Score)
+
+		s := <-ctx.SetMetadataCh
+		equals(t, "s", DataToString(s.Key))
+		equals(t, int64(1), s.Len)
+		se := <-ctx.SetDataCh
+		equals(t, "e", DataToString(se))
+	}()
+
+	lp := newListpack("k", "v")
+	br.WriteByte(16) // hash map in listpack encoding
+	br.Write([]byte{1, 'h'})
+	br.WriteByte(byte(len(lp)))
+	br.Write(lp)
+
+	lp = newListpack("m", int64(2))
+	br.WriteByte(17) // sorted set in listpack encoding
+	br.Write([]byte{1, 'z'})
+	br.WriteByte(byte(len(lp)))
+	br.Write(lp)
+
+	lp = newListpack("e")
+	br.WriteByte(20) // set in listpack encoding
+	br.Write([]byte{1, 's'})
+	br.WriteByte(byte(len(lp)))
+	br.Write(lp)
+	br.Flush()
+
+	r := bufio.NewReader(&buffer)
+	for i := 0; i < 3; i++ {
+		err := p.readKeyValuePair(r)
+		ok(t, err)
+	}
+}
+
+func TestReadKeyValuePairHashMapWithFieldExpirationEncodings(t *testing.T) {
+	var buffer bytes.Buffer
+
+	br := bufio.NewWriter(&buffer)
+
+	ctx := ParserContext{
+		HashMetadataCh: make(chan HashMetadata),
+		HashDataCh:     make(chan HashEntry),
+	}
+	p := &parser{h: newChannelHandler(ctx)}
+
+	expiry := time.Date(2100, time.January, 1, 0, 0, 0, 0, time.UTC)
+	ms := uint64(expiry.UnixNano() / int64(time.Millisecond))
+
+	go func() {
+		for _, key := range []string{"a", "b", "c", "d"} {
+			h := <-ctx.HashMetadataCh
+			equals(t, key, DataToString(h.Key))
+			equals(t, int64(1), h.Len)
+			he := <-ctx.HashDataCh
+			equals(t, "k", DataToString(he.Key))
+			equals(t, "v", DataToString(he.Value))
+			equals(t, expiry, he.ExpiryTime)
+		}
+	}()
+
+	br.WriteByte(22) // hash map with metadata, pre GA
+	br.Write([]byte{1, 'a'})
+	writeLen(br, 1)
+	writeLen(br, ms)
+	br.Write([]byte{1, 'k', 1, 'v'})
+
+	lp := newListpack("k", "v", int64(ms))
+	br.WriteByte(23) // hash map in listpack with expiration times, pre GA
+	br.Write([]byte{1, 'b'})
+	writeString(br, string(lp))
+
+	br.WriteByte(24) // hash map with metadata
+	br.Write([]byte{1, 'c'})
+	binary.Write(br, binary.LittleEndian, ms)
+	writeLen(br, 1)
+	writeLen(br, 1)
+	br.Write([]byte{1, 'k', 1, 'v'})
+
+	br.WriteByte(25) // hash map in listpack with expiration times
+	br.Write([]byte{1, 'd'})
+	binary.Write(br, binary.LittleEndian, ms)
+	writeString(br, string(lp))
+	br.Flush()
+
+	r := bufio.NewReader(&buffer)
+	for i := 0; i < 4; i++ {
+		err := p.readKeyValuePair(r)
+		ok(t, err)
+	}
+}
+
+func TestReadKeyValuePairModuleEncoding(t *testing.T) {
+	var buffer bytes.Buffer
+
+	br := bufio.NewWriter(&buffer)
+
+	ctx := ParserContext{ModuleObjectCh: make(chan ModuleObject)}
+	p := &parser{h: newChannelHandler(ctx)}
+
+	go func() {
+		m := <-ctx.ModuleObjectCh
+		equals(t, "a", DataToString(m.Key))
+		equals(t, "nodecoder", m.ModuleName)
+		equals(t, []byte{moduleOpcodeEOF}, m.Raw)
+	}()
+
+	br.WriteByte(7) // module encoding
+	br.Write([]byte{1, 'a'})
+	writeLen(br, mustEncodeModuleID("nodecoder", 1))
+	writeLen(br, moduleOpcodeEOF)
+	br.Flush()
+
+	err := p.readKeyValuePair(bufio.NewReader(&buffer))
+	ok(t, err)
+
+	// Module without opcodes and without decoder
+	buffer.Reset()
+	br.WriteByte(6)
+	br.Write([]byte{1, 'a'})
+	writeLen(br, mustEncodeModuleID("nodecoder", 1))
+	br.Flush()
+
+	err = p.readKeyValuePair(bufio.NewReader(&buffer))
+	equals(t, ErrUnknownModuleType, err)
+}
+
+func TestReadKeyValuePairSortedSet2Encoding(t *testing.T) {
+	var buffer bytes.Buffer
+
+	br := bufio.NewWriter(&buffer)
+
+	ctx := ParserContext{
+		SortedSetMetadataCh: make(chan SortedSetMetadata),
+		SortedSetEntriesCh:  make(chan SortedSetEntry),
+	}
+	p := &parser{h: newChannelHandler(ctx)}
+
+	go func() {
+		l := <-ctx.SortedSetMetadataCh
+		equals(t, "a", DataToString(l.Key))
+		equals(t, int64(1), l.Len)
+
+		e := <-ctx.SortedSetEntriesCh
+		equals(t, "b", DataToString(e.Value))
+		equals(t, 0.5, e.Score)
+	}()
+
+	br.WriteByte(5) // sorted set 2 encoding
+	br.Write([]byte{1, 'a'})
+	br.WriteByte(1)
+	br.Write([]byte{1, 'b'})
+	binary.Write(br, binary.LittleEndian, 0.5)
+	br.Flush()
+
+	err := p.readKeyValuePair(bufio.NewReader(&buffer))
+	ok(t, err)
+}
+
+func TestReadKeyValuePairUnknownValueType(t *testing.T) {
+	var buffer bytes.Buffer
+
+	br := bufio.NewWriter(&buffer)
+
+	br.WriteByte(0xF0)
+	br.WriteByte(1)
+	br.WriteByte('a')
+	br.Flush()
+
+	p := &parser{h: NopHandler{}}
+	err := p.readKeyValuePair(bufio.NewReader(&buffer))
+	equals(t, ErrUnknownValueType, err)
+}
+
+func TestParse(t *testing.T) {
+	var buffer bytes.Buffer
+
+	ctx := ParserContext{
+		DbCh:           make(chan int),
+		StringObjectCh: make(chan StringObject),
+		endOfFileCh:    make(chan struct{}),
+	}
+	p := &parser{h: newChannelHandler(ctx)}
+
+	br := bufio.NewWriter(&buffer)
+	br.WriteString("REDIS")  // magic string
+	br.WriteString("0004")   // RDB version TODO use version >= 5 and handle checksum
+	br.WriteByte(0xFE)       // next database byte
+	br.WriteByte(0)          // database number
+	br.WriteByte(0)          // string
+	br.WriteByte(1)          // key len
+	br.WriteByte('a')        // key data
+	br.WriteByte(6)          // string len
+	br.WriteString("foobar") // string data
+	br.WriteByte(0xFF)       // end of file
+	br.Flush()
+
+	go mustParse(t, p, ctx, bufio.NewReader(&buffer))
+
+	stop := false
+	for !stop {
+		select {
+		case v, ok := <-ctx.StringObjectCh:
+			if !ok {
+				ctx.StringObjectCh = nil
+				break
+			}
+			equals(t, "a", DataToString(v.Key.Key))
+			equals(t, "foobar", DataToString(v.Value))
+		case v, ok := <-ctx.DbCh:
+			if !ok {
+				ctx.DbCh = nil
+				break
+			}
+			equals(t, int(0), v)
+		}
+
+		if ctx.Invalid() {
+			break
+		}
+	}
+}
+
+func TestParseAllTypes(t *testing.T) {
 	var buffer bytes.Buffer
 
 	ctx := ParserContext{
@@ -1205,7 +1575,7 @@ func TestParseAllTypes(t *testing.T) {
 		SortedSetEntriesCh:  make(chan SortedSetEntry),
 		endOfFileCh:         make(chan struct{}),
 	}
-	p := &parser{ctx: ctx}
+	p := &parser{h: newChannelHandler(ctx)}
 
 	br := bufio.NewWriter(&buffer)
 	br.WriteString("REDIS") // magic string
@@ -1364,7 +1734,7 @@ func TestParseNoKeyValuePair(t *testing.T) {
 	var buffer bytes.Buffer
 
 	ctx := ParserContext{DbCh: make(chan int)}
-	p := &parser{ctx: ctx}
+	p := &parser{h: newChannelHandler(ctx)}
 
 	go func() {
 		v := <-ctx.DbCh
@@ -1382,3 +1752,701 @@ func TestParseNoKeyValuePair(t *testing.T) {
 	err := p.Parse(bufio.NewReader(&buffer))
 	equals(t, io.EOF, err)
 }
+
+func TestParseErrorClosesChannels(t *testing.T) {
+	var buffer bytes.Buffer
+
+	br := bufio.NewWriter(&buffer)
+	br.WriteString("REDIS0006")
+	br.WriteByte(0xFE) // next database byte
+	br.WriteByte(0)    // database number
+	br.WriteByte(0)    // string
+	writeString(br, "a")
+	writeString(br, "foo")
+	br.WriteByte(0xF0) // unknown value type
+	writeString(br, "b")
+	br.Flush()
+
+	ctx := ParserContext{
+		StringObjectCh: make(chan StringObject),
+		ErrCh:          make(chan error, 1),
+	}
+	p := NewParser(ctx)
+
+	done := make(chan error)
+	go func() {
+		done <- p.Parse(&buffer)
+	}()
+
+	var keys []string
+	var errs []error
+	for !ctx.Invalid() {
+		select {
+		case v, ok := <-ctx.StringObjectCh:
+			if !ok {
+				ctx.StringObjectCh = nil
+				break
+			}
+			keys = append(keys, DataToString(v.Key))
+		case err, ok := <-ctx.ErrCh:
+			if !ok {
+				ctx.ErrCh = nil
+				break
+			}
+			errs = append(errs, err)
+		}
+	}
+
+	equals(t, ErrUnknownValueType, <-done)
+	equals(t, []string{"a"}, keys)
+	equals(t, []error{ErrUnknownValueType}, errs)
+}
+
+func TestParseContextCancel(t *testing.T) {
+	var buffer bytes.Buffer
+
+	br := bufio.NewWriter(&buffer)
+	br.WriteString("REDIS0006")
+	br.WriteByte(0xFE) // next database byte
+	br.WriteByte(0)    // database number
+	for _, key := range []string{"a", "b", "c"} {
+		br.WriteByte(0) // string
+		writeString(br, key)
+		writeString(br, "foo")
+	}
+	br.WriteByte(0xFF) // end of file
+	br.Flush()
+
+	ctx := ParserContext{
+		StringObjectCh: make(chan StringObject),
+		ErrCh:          make(chan error, 1),
+	}
+	p := NewParser(ctx)
+
+	cctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- p.ParseContext(cctx, &buffer)
+	}()
+
+	// Stop reading after the first key, the parser must not stay blocked
+	v := <-ctx.StringObjectCh
+	equals(t, "a", DataToString(v.Key))
+	cancel()
+
+	equals(t, context.Canceled, <-done)
+	equals(t, context.Canceled, <-ctx.ErrCh)
+
+	_, ok := <-ctx.StringObjectCh
+	equals(t, false, ok)
+}
+
+func TestNewParserUnbufferedErrCh(t *testing.T) {
+	defer func() {
+		equals(t, "rdbtools: ParserContext.ErrCh must be buffered", recover())
+	}()
+
+	NewParser(ParserContext{ErrCh: make(chan error)})
+	t.Fatal("expected a panic")
+}
+
+func TestParseContextDeadline(t *testing.T) {
+	var buffer bytes.Buffer
+	buffer.WriteString("REDIS0006")
+
+	cctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+
+	err := NewHandlerParser(NopHandler{}).ParseContext(cctx, &buffer)
+	equals(t, context.DeadlineExceeded, err)
+}
+
+func TestParseContextBlockedRead(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	cctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- NewHandlerParser(NopHandler{}).ParseContext(cctx, client)
+	}()
+
+	// The parser waits for the rest of the file
+	io.WriteString(server, "REDIS0006")
+	cancel()
+
+	equals(t, context.Canceled, <-done)
+
+	// The read deadline set to interrupt the parser is cleared
+	go io.WriteString(server, "foo")
+	data := make([]byte, 3)
+	_, err := io.ReadFull(client, data)
+	ok(t, err)
+	equals(t, "foo", string(data))
+}
+
+// The consumer reading ErrCh with the other channels gets the error of a cancelled parser
+func TestParseContextCancelErrChInLoop(t *testing.T) {
+	var buffer bytes.Buffer
+
+	br := bufio.NewWriter(&buffer)
+	br.WriteString("REDIS0006")
+	br.WriteByte(0xFE) // next database byte
+	br.WriteByte(0)    // database number
+	for i := 0; i < 50; i++ {
+		br.WriteByte(0) // string
+		writeString(br, strconv.Itoa(i))
+		writeString(br, "foo")
+	}
+	br.WriteByte(0xFF) // end of file
+	br.Flush()
+
+	ctx := ParserContext{
+		StringObjectCh: make(chan StringObject),
+		ErrCh:          make(chan error, 1),
+	}
+	p := NewParser(ctx)
+
+	cctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error)
+	go func() {
+		done <- p.ParseContext(cctx, &buffer)
+	}()
+
+	var keys int
+	var errs []error
+	for !ctx.Invalid() {
+		select {
+		case _, ok := <-ctx.StringObjectCh:
+			if !ok {
+				ctx.StringObjectCh = nil
+				break
+			}
+			keys++
+			if keys == 5 {
+				cancel()
+			}
+		case err, ok := <-ctx.ErrCh:
+			if !ok {
+				ctx.ErrCh = nil
+				break
+			}
+			errs = append(errs, err)
+		}
+
+		// Processing the event takes some time
+		time.Sleep(time.Millisecond)
+	}
+
+	equals(t, context.Canceled, <-done)
+	equals(t, []error{context.Canceled}, errs)
+}
+
+// Append the CRC64 checksum of the buffer content to the buffer
+func writeChecksum(buffer *bytes.Buffer) {
+	cr := newChecksumReader(bytes.NewReader(buffer.Bytes()))
+	io.Copy(io.Discard, cr)
+	binary.Write(buffer, binary.LittleEndian, cr.checksum)
+}
+
+func TestParseAuxFields(t *testing.T) {
+	var buffer bytes.Buffer
+
+	ctx := ParserContext{
+		DbCh:           make(chan int),
+		AuxFieldCh:     make(chan AuxField),
+		StringObjectCh: make(chan StringObject),
+	}
+	p := NewParser(ctx)
+
+	br := bufio.NewWriter(&buffer)
+	br.WriteString("REDIS") // magic string
+	br.WriteString("0011")  // RDB version
+	br.WriteByte(0xFA)      // aux field
+	br.WriteByte(9)         // aux key len
+	br.WriteString("redis-ver")
+	br.WriteByte(5) // aux value len
+	br.WriteString("7.2.4")
+	br.WriteByte(0xFA) // aux field
+	br.WriteByte(5)    // aux key len
+	br.WriteString("ctime")
+	br.WriteByte(0xC2) // INT32 encoding
+	binary.Write(br, binary.LittleEndian, int32(1700000000))
+	br.WriteByte(0xFE)       // next database byte
+	br.WriteByte(0)          // database number
+	br.WriteByte(0)          // string
+	br.Write([]byte{1, 'a'}) // key
+	br.WriteByte(6)          // string len
+	br.WriteString("foobar") // string data
+	br.WriteByte(0xFF)       // end of file
+	br.Flush()
+	writeChecksum(&buffer)
+
+	go mustParse(t, p, ctx, bufio.NewReader(&buffer))
+
+	var fields []AuxField
+	var stringObjects int
+	for {
+		select {
+		case v, ok := <-ctx.DbCh:
+			if !ok {
+				ctx.DbCh = nil
+				break
+			}
+			equals(t, int(0), v)
+		case v, ok := <-ctx.AuxFieldCh:
+			if !ok {
+				ctx.AuxFieldCh = nil
+				break
+			}
+			fields = append(fields, v)
+		case v, ok := <-ctx.StringObjectCh:
+			if !ok {
+				ctx.StringObjectCh = nil
+				break
+			}
+			equals(t, "a", DataToString(v.Key.Key))
+			stringObjects++
+		}
+
+		if ctx.Invalid() {
+			break
+		}
+	}
+
+	equals(t, 2, len(fields))
+	equals(t, "redis-ver", DataToString(fields[0].Key))
+	equals(t, "7.2.4", DataToString(fields[0].Value))
+	equals(t, "ctime", DataToString(fields[1].Key))
+	equals(t, int32(1700000000), fields[1].Value)
+	equals(t, 1, stringObjects)
+}
+
+func TestParseFunctionLibraries(t *testing.T) {
+	var buffer bytes.Buffer
+
+	ctx := ParserContext{
+		StringObjectCh:    make(chan StringObject),
+		FunctionLibraryCh: make(chan FunctionLibrary),
+	}
+	p := NewParser(ctx)
+
+	br := bufio.NewWriter(&buffer)
+	br.WriteString("REDIS") // magic string
+	br.WriteString("0011")  // RDB version
+	br.WriteByte(0xF5)      // function library
+	writeString(br, "#!lua name=lib1\nreturn")
+	br.WriteByte(0xFE)       // next database byte
+	br.WriteByte(0)          // database number
+	br.WriteByte(0)          // string
+	br.Write([]byte{1, 'a'}) // key
+	br.WriteByte(6)          // string len
+	br.WriteString("foobar") // string data
+	br.WriteByte(0xF5)       // function library
+	writeString(br, "#!lua name=lib2\nreturn")
+	br.WriteByte(0xFF) // end of file
+	br.Flush()
+	writeChecksum(&buffer)
+
+	go mustParse(t, p, ctx, bufio.NewReader(&buffer))
+
+	var libs []string
+	var stringObjects int
+	for {
+		select {
+		case _, ok := <-ctx.StringObjectCh:
+			if !ok {
+				ctx.StringObjectCh = nil
+				break
+			}
+			stringObjects++
+		case v, ok := <-ctx.FunctionLibraryCh:
+			if !ok {
+				ctx.FunctionLibraryCh = nil
+				break
+			}
+			libs = append(libs, v.Name)
+		}
+
+		if ctx.Invalid() {
+			break
+		}
+	}
+
+	equals(t, []string{"lib1", "lib2"}, libs)
+	equals(t, 1, stringObjects)
+}
+
+func TestParseDisabledChecksum(t *testing.T) {
+	var buffer bytes.Buffer
+
+	p := NewParser(ParserContext{})
+
+	br := bufio.NewWriter(&buffer)
+	br.WriteString("REDIS")
+	br.WriteString("0009")
+	br.WriteByte(0xFF)
+	binary.Write(br, binary.LittleEndian, uint64(0))
+	br.Flush()
+
+	err := p.Parse(bufio.NewReader(&buffer))
+	ok(t, err)
+}
+
+func TestParseInvalidChecksum(t *testing.T) {
+	var buffer bytes.Buffer
+
+	p := NewParser(ParserContext{})
+
+	br := bufio.NewWriter(&buffer)
+	br.WriteString("REDIS")
+	br.WriteString("0009")
+	br.WriteByte(0xFF)
+	binary.Write(br, binary.LittleEndian, uint64(1))
+	br.Flush()
+
+	err := p.Parse(bufio.NewReader(&buffer))
+	equals(t, ErrInvalidChecksum, err)
+}
+
+func TestParseResizeDB(t *testing.T) {
+	var buffer bytes.Buffer
+
+	ctx := ParserContext{
+		DatabaseMetadataCh: make(chan DatabaseMetadata),
+		StringObjectCh:     make(chan StringObject),
+	}
+	p := NewParser(ctx)
+
+	br := bufio.NewWriter(&buffer)
+	br.WriteString("REDIS")    // magic string
+	br.WriteString("0009")     // RDB version
+	br.WriteByte(0xFE)         // next database byte
+	br.Write([]byte{0x40, 64}) // database number
+	br.WriteByte(0xFB)         // resize db
+	br.WriteByte(1)            // main dict size
+	br.WriteByte(0)            // expires dict size
+	br.WriteByte(0)            // string
+	br.Write([]byte{1, 'a'})   // key
+	br.Write([]byte{1, 'b'})   // value
+	br.WriteByte(0xFE)         // next database byte
+	br.WriteByte(1)            // database number
+	br.WriteByte(0xFF)         // end of file
+	br.Flush()
+	writeChecksum(&buffer)
+
+	go mustParse(t, p, ctx, bufio.NewReader(&buffer))
+
+	var metadata []DatabaseMetadata
+	for {
+		select {
+		case v, ok := <-ctx.DatabaseMetadataCh:
+			if !ok {
+				ctx.DatabaseMetadataCh = nil
+				break
+			}
+			metadata = append(metadata, v)
+		case v, ok := <-ctx.StringObjectCh:
+			if !ok {
+				ctx.StringObjectCh = nil
+				break
+			}
+			equals(t, "a", DataToString(v.Key.Key))
+			equals(t, 1, len(metadata))
+		}
+
+		if ctx.Invalid() {
+			break
+		}
+	}
+
+	equals(t, []DatabaseMetadata{
+		{Number: 64, Len: 1, ExpiresLen: 0},
+		{Number: 1, Len: -1, ExpiresLen: -1},
+	}, metadata)
+}
+
+func TestParseSlotInfo(t *testing.T) {
+	var buffer bytes.Buffer
+
+	ctx := ParserContext{
+		StringObjectCh: make(chan StringObject),
+		SlotInfoCh:     make(chan SlotInfo),
+	}
+	p := NewParser(ctx)
+
+	br := bufio.NewWriter(&buffer)
+	br.WriteString("REDIS") // magic string
+	br.WriteString("0012")  // RDB version
+	br.WriteByte(0xFE)      // next database byte
+	br.WriteByte(0)         // database number
+	br.WriteByte(0xFB)      // resize db
+	br.WriteByte(1)         // main dict size
+	br.WriteByte(0)         // expires dict size
+	br.WriteByte(0xF4)      // slot info
+	writeLen(br, 12182)     // slot
+	br.WriteByte(1)         // slot size
+	br.WriteByte(0)         // expires slot size
+	br.WriteByte(0)         // string
+	writeString(br, "foo")  // key
+	writeString(br, "bar")  // value
+	br.WriteByte(0xFF)      // end of file
+	br.Flush()
+	writeChecksum(&buffer)
+
+	go mustParse(t, p, ctx, bufio.NewReader(&buffer))
+
+	var slots []SlotInfo
+	for {
+		select {
+		case v, ok := <-ctx.SlotInfoCh:
+			if !ok {
+				ctx.SlotInfoCh = nil
+				break
+			}
+			slots = append(slots, v)
+		case v, ok := <-ctx.StringObjectCh:
+			if !ok {
+				ctx.StringObjectCh = nil
+				break
+			}
+			equals(t, "foo", DataToString(v.Key.Key))
+			equals(t, 12182, v.Key.Slot)
+		}
+
+		if ctx.Invalid() {
+			break
+		}
+	}
+
+	equals(t, []SlotInfo{{Slot: 12182, Len: 1, ExpiresLen: 0}}, slots)
+}
+
+func TestParseModuleAux(t *testing.T) {
+	var buffer bytes.Buffer
+
+	ctx := ParserContext{
+		StringObjectCh: make(chan StringObject),
+		ModuleAuxCh:    make(chan ModuleAux),
+	}
+	p := NewParser(ctx)
+
+	writeModuleAux := func(w io.Writer, when uint64) {
+		w.Write([]byte{0xF7}) // module aux
+		writeLen(w, mustEncodeModuleID("ft-index0", 2))
+		writeLen(w, moduleOpcodeUInt)
+		writeLen(w, when)
+		writeLen(w, moduleOpcodeString)
+		writeString(w, "state")
+		writeLen(w, moduleOpcodeEOF)
+	}
+
+	br := bufio.NewWriter(&buffer)
+	br.WriteString("REDIS") // magic string
+	br.WriteString("0009")  // RDB version
+	writeModuleAux(br, ModuleAuxBeforeRDB)
+	br.WriteByte(0xFE)     // next database byte
+	br.WriteByte(0)        // database number
+	br.WriteByte(0)        // string
+	writeString(br, "foo") // key
+	writeString(br, "bar") // value
+	writeModuleAux(br, ModuleAuxAfterRDB)
+	br.WriteByte(0xFF) // end of file
+	br.Flush()
+	writeChecksum(&buffer)
+
+	go mustParse(t, p, ctx, bufio.NewReader(&buffer))
+
+	var when []int
+	var stringObjects int
+	for {
+		select {
+		case v, ok := <-ctx.ModuleAuxCh:
+			if !ok {
+				ctx.ModuleAuxCh = nil
+				break
+			}
+			equals(t, "ft-index0", v.ModuleName)
+			when = append(when, v.When)
+		case _, ok := <-ctx.StringObjectCh:
+			if !ok {
+				ctx.StringObjectCh = nil
+				break
+			}
+			stringObjects++
+		}
+
+		if ctx.Invalid() {
+			break
+		}
+	}
+
+	equals(t, []int{ModuleAuxBeforeRDB, ModuleAuxAfterRDB}, when)
+	equals(t, 1, stringObjects)
+}
+
+// Write a RDB file created at 2020-01-01 with a string key expiring an hour later,
+// returning the creation time
+func writeSnapshotTimeRDB(buffer *bytes.Buffer) time.Time {
+	ctime := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	expiry := ctime.Add(time.Hour)
+
+	br := bufio.NewWriter(buffer)
+	br.WriteString("REDIS") // magic string
+	br.WriteString("0009")  // RDB version
+	br.WriteByte(0xFA)      // aux field
+	writeString(br, "ctime")
+	br.WriteByte(0xC2) // INT32 encoding
+	binary.Write(br, binary.LittleEndian, int32(ctime.Unix()))
+	br.WriteByte(0xFE) // next database byte
+	br.WriteByte(0)    // database number
+	br.WriteByte(0xFC) // expiry in milliseconds
+	binary.Write(br, binary.LittleEndian, expiry.UnixNano()/int64(time.Millisecond))
+	br.WriteByte(0)        // string
+	writeString(br, "foo") // key
+	writeString(br, "bar") // value
+	br.WriteByte(0xFF)     // end of file
+	br.Flush()
+	writeChecksum(buffer)
+
+	return ctime
+}
+
+func TestParseExpireAtSnapshotTime(t *testing.T) {
+	var buffer bytes.Buffer
+
+	ctx := ParserContext{
+		StringObjectCh:       make(chan StringObject),
+		ExpireAtSnapshotTime: true,
+	}
+	p := NewParser(ctx)
+
+	ctime := writeSnapshotTimeRDB(&buffer)
+
+	go mustParse(t, p, ctx, bufio.NewReader(&buffer))
+
+	var keys []KeyObject
+	for {
+		select {
+		case v, ok := <-ctx.StringObjectCh:
+			if !ok {
+				ctx.StringObjectCh = nil
+				break
+			}
+			keys = append(keys, v.Key)
+		}
+
+		if ctx.Invalid() {
+			break
+		}
+	}
+
+	equals(t, 1, len(keys))
+	equals(t, ctime, keys[0].ExpiryReference)
+	equals(t, false, keys[0].Expired())
+	equals(t, true, keys[0].ExpiredAt(time.Now()))
+}
+
+func TestParseValkey(t *testing.T) {
+	var buffer bytes.Buffer
+
+	ctx := ParserContext{
+		HeaderCh:       make(chan Header),
+		StringObjectCh: make(chan StringObject),
+	}
+	p := NewParser(ctx)
+
+	br := bufio.NewWriter(&buffer)
+	br.WriteString("VALKEY") // magic string
+	br.WriteString("080")    // RDB version
+	br.WriteByte(0xFE)       // next database byte
+	br.WriteByte(0)          // database number
+	br.WriteByte(0)          // string
+	writeString(br, "foo")   // key
+	writeString(br, "bar")   // value
+	br.WriteByte(0xFF)       // end of file
+	br.Flush()
+	writeChecksum(&buffer)
+
+	go mustParse(t, p, ctx, bufio.NewReader(&buffer))
+
+	var headers []Header
+	var stringObjects int
+	for {
+		select {
+		case v, ok := <-ctx.HeaderCh:
+			if !ok {
+				ctx.HeaderCh = nil
+				break
+			}
+			headers = append(headers, v)
+		case _, ok := <-ctx.StringObjectCh:
+			if !ok {
+				ctx.StringObjectCh = nil
+				break
+			}
+			stringObjects++
+		}
+
+		if ctx.Invalid() {
+			break
+		}
+	}
+
+	equals(t, []Header{{Flavour: FlavourValkey, Version: 80}}, headers)
+	equals(t, 1, stringObjects)
+}
+
+func TestReadKeyValuePairValkeyUnknownValueType(t *testing.T) {
+	var buffer bytes.Buffer
+
+	br := bufio.NewWriter(&buffer)
+	br.WriteByte(24) // Redis hash map with metadata
+	writeString(br, "a")
+	br.Flush()
+
+	p := &parser{h: NopHandler{}, header: Header{Flavour: FlavourValkey, Version: 80}}
+	err := p.readKeyValuePair(bufio.NewReader(&buffer))
+	equals(t, ErrUnknownValueType, err)
+}
+
+func TestReadKeyValuePairValkeyHashWithFieldExpiry(t *testing.T) {
+	var buffer bytes.Buffer
+
+	br := bufio.NewWriter(&buffer)
+	br.WriteByte(22) // Valkey hash with field expiration times
+	writeString(br, "hash")
+	writeLen(br, 2)
+	binary.Write(br, binary.LittleEndian, int64(1700000000500))
+	writeString(br, "f1")
+	writeString(br, "v1")
+	binary.Write(br, binary.LittleEndian, int64(-1))
+	writeString(br, "f2")
+	writeString(br, "v2")
+	br.Flush()
+
+	h := &recordingHandler{}
+	p := &parser{h: h, header: Header{Flavour: FlavourValkey, Version: 80}}
+	err := p.readKeyValuePair(bufio.NewReader(&buffer))
+	ok(t, err)
+
+	equals(t, []string{
+		"StartHash hash",
+		"HashEntry{Key: f1, Value: v1, ExpiryTime: 2023-11-14 22:13:20.5 +0000 UTC}",
+		"HashEntry{Key: f2, Value: v2}",
+		"EndHash hash",
+	}, h.calls)
+}
+
+// Write a length using the RDB length encoding
+func writeLen(w io.Writer, l uint64) {
+	encodeLen(w, l)
+}
+
+// Write a length prefixed string
+func writeString(w io.Writer, s string) {
+	writeLen(w, uint64(len(s)))
+	io.WriteString(w, s)
+}
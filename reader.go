@@ -0,0 +1,268 @@
+package rdbtools
+
+import (
+	"io"
+)
+
+// Represents a key of a RDB file read by a Reader
+type Record struct {
+	DB       int
+	Key      KeyObject
+	Type     string // string, list, set, zset, hash, stream or module
+	Encoding string // The encoding of the value in the file, such as listpack
+
+	r       *Reader
+	seq     int // The position of the record, to check it's still the current one
+	rdbType byte
+}
+
+// The type and encoding of each RDB value type
+var recordTypes = map[byte][2]string{
+	0:  {"string", "string"},
+	1:  {"list", "linkedlist"},
+	2:  {"set", "hashtable"},
+	3:  {"zset", "skiplist"},
+	4:  {"hash", "hashtable"},
+	5:  {"zset", "skiplist"},
+	6:  {"module", "module"},
+	7:  {"module", "module"},
+	9:  {"hash", "zipmap"},
+	10: {"list", "ziplist"},
+	11: {"set", "intset"},
+	12: {"zset", "ziplist"},
+	13: {"hash", "ziplist"},
+	14: {"list", "quicklist"},
+	15: {"stream", "listpacks"},
+	16: {"hash", "listpack"},
+	17: {"zset", "listpack"},
+	18: {"list", "quicklist"},
+	19: {"stream", "listpacks"},
+	20: {"set", "listpack"},
+	21: {"stream", "listpacks"},
+	22: {"hash", "hashtable"},
+	23: {"hash", "listpack"},
+	24: {"hash", "hashtable"},
+	25: {"hash", "listpack"},
+}
+
+// Decode the value of the record, which must be done before the next call to Next.
+// The value has the same type as the values returned by DecodeDumpPayload.
+func (rec Record) Value() (interface{}, error) {
+	r := rec.r
+	if r == nil || r.seq != rec.seq || !r.pending {
+		return nil, ErrValueNotAvailable
+	}
+	r.pending = false
+
+	h := &dumpHandler{}
+	r.p.h = h
+	defer func() { r.p.h = NopHandler{} }()
+
+	if err := r.p.readValue(rec.Key, rec.rdbType, r.cr); err != nil {
+		r.err = err
+		return nil, err
+	}
+
+	return h.result(rec.rdbType), nil
+}
+
+// Reader reads the keys of a RDB file one by one, without decoding
+// the values which are not needed.
+type Reader struct {
+	p  *parser
+	cr *checksumReader
+
+	started bool   // Whether the header was read
+	inDB    bool   // Whether the keys of a database are being read
+	seq     int    // The number of records read
+	last    Record // The last record
+	pending bool   // Whether the value of the last record is still to be read
+	err     error  // The error which stopped the reader, io.EOF at the end of the file
+}
+
+// Create a new reader reading the RDB file from r
+func NewReader(r io.Reader, opts ...ParserOption) *Reader {
+	p := &parser{h: NopHandler{}}
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	return &Reader{p: p, cr: newChecksumReader(r)}
+}
+
+// Returns the next record, or io.EOF at the end of the file
+func (r *Reader) Next() (Record, error) {
+	if r.err != nil {
+		return Record{}, r.err
+	}
+
+	rec, err := r.next()
+	if err != nil {
+		r.err = err
+		return Record{}, err
+	}
+
+	return rec, nil
+}
+
+func (r *Reader) next() (Record, error) {
+	p := r.p
+
+	if !r.started {
+		var err error
+		if p.header, err = readHeader(r.cr); err != nil {
+			return Record{}, err
+		}
+		r.started = true
+	}
+
+	if r.pending {
+		r.pending = false
+		if err := p.skipValue(r.last.Key, r.last.rdbType, r.cr); err != nil {
+			return Record{}, err
+		}
+	}
+
+	for {
+		if !r.inDB {
+			err := p.readDatabase(r.cr)
+			if err == errNoMoreDatabases {
+				return Record{}, r.end()
+			}
+			if err != nil {
+				return Record{}, err
+			}
+			r.inDB = true
+		}
+
+		key, b, err := p.readKey(r.cr)
+		if err == errNoMoreKeyValuePair {
+			r.inDB = false
+			if p.scratch[0] == 0xFF {
+				return Record{}, r.end()
+			}
+			continue
+		}
+		if err != nil {
+			return Record{}, err
+		}
+
+		if err := p.header.checkValueType(b); err != nil {
+			return Record{}, err
+		}
+
+		types, ok := recordTypes[b]
+		if !ok {
+			return Record{}, ErrUnknownValueType
+		}
+
+		r.seq++
+		r.last = Record{DB: p.db, Key: key, Type: types[0], Encoding: types[1], r: r, seq: r.seq, rdbType: b}
+		r.pending = true
+
+		return r.last, nil
+	}
+}
+
+// Check the checksum at the end of the file
+func (r *Reader) end() error {
+	if err := r.p.readChecksum(r.cr); err != nil {
+		return err
+	}
+
+	return io.EOF
+}
+
+// Skip a value of type b without decoding it. The values which can't be skipped,
+// such as streams and module values, are decoded and discarded.
+func (p *parser) skipValue(key KeyObject, b byte, r io.Reader) error {
+	switch b {
+	case 0, 9, 10, 11, 12, 13, 16, 17, 20:
+		return p.skipString(r)
+	case 1, 2, 3, 4, 5, 14, 18:
+		l, e, err := p.readLen(r)
+		if err != nil {
+			return err
+		}
+		if e {
+			return ErrUnexpectedEncodedLength
+		}
+
+		for i := uint64(0); i < l; i++ {
+			if err := p.skipElement(b, r); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	default:
+		return p.readValue(key, b, r)
+	}
+}
+
+// Skip an element of a collection of type b
+func (p *parser) skipElement(b byte, r io.Reader) error {
+	switch b {
+	case 3: // Value and score as a string
+		if err := p.skipString(r); err != nil {
+			return err
+		}
+		_, err := p.readDoubleValue(r)
+		return err
+	case 4: // Field and value
+		if err := p.skipString(r); err != nil {
+			return err
+		}
+		return p.skipString(r)
+	case 5: // Value and binary score
+		if err := p.skipString(r); err != nil {
+			return err
+		}
+		_, err := readBytes(r, 8)
+		return err
+	case 18: // Container type and node
+		if _, _, err := p.readLen(r); err != nil {
+			return err
+		}
+		return p.skipString(r)
+	default:
+		return p.skipString(r)
+	}
+}
+
+// Skip a string without decompressing it
+func (p *parser) skipString(r io.Reader) error {
+	l, e, err := p.readLen(r)
+	if err != nil {
+		return err
+	}
+
+	if e {
+		switch l {
+		case 0: // INT8
+			l = 1
+		case 1: // INT16
+			l = 2
+		case 2: // INT32
+			l = 4
+		case 3: // LZF
+			if l, _, err = p.readLen(r); err != nil {
+				return err
+			}
+			if _, _, err = p.readLen(r); err != nil {
+				return err
+			}
+		default:
+			return ErrUnknownLengthEncoding
+		}
+	}
+
+	if _, err := io.CopyN(io.Discard, r, int64(l)); err != nil {
+		if err == io.EOF {
+			return io.ErrUnexpectedEOF
+		}
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,190 @@
+package rdbtools
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeReaderTestRDB(w *bufio.Writer) {
+	w.WriteString("REDIS0011")
+	w.WriteByte(0xFE) // next database byte
+	w.WriteByte(0)    // database number
+	w.WriteByte(0)    // string
+	writeString(w, "a")
+	writeString(w, "foo")
+	w.WriteByte(5) // sorted set with binary scores
+	writeString(w, "b")
+	writeLen(w, 1)
+	writeString(w, "x")
+	w.Write([]byte{0, 0, 0, 0, 0, 0, 0xf8, 0x3f}) // 1.5
+	w.WriteByte(0xFE)                             // next database byte
+	w.WriteByte(2)                                // database number
+	w.WriteByte(20)                               // set in listpack encoding
+	writeString(w, "c")
+	writeString(w, string(newListpack("m", int64(1))))
+	w.WriteByte(4) // hash
+	writeString(w, "d")
+	writeLen(w, 1)
+	writeString(w, "f")
+	writeString(w, "v")
+	w.WriteByte(0xFF) // end of file
+	w.Flush()
+}
+
+// Read all the records, decoding the values of the keys in decode
+func readAllRecords(t *testing.T, r *Reader, decode ...string) ([]Record, map[string]interface{}) {
+	var records []Record
+	values := make(map[string]interface{})
+	for {
+		rec, err := r.Next()
+		if err == io.EOF {
+			return records, values
+		}
+		ok(t, err)
+		records = append(records, rec)
+
+		for _, k := range decode {
+			if DataToString(rec.Key) == k {
+				values[k], err = rec.Value()
+				ok(t, err)
+			}
+		}
+	}
+}
+
+func TestReader(t *testing.T) {
+	var buffer bytes.Buffer
+	br := bufio.NewWriter(&buffer)
+	writeReaderTestRDB(br)
+	writeChecksum(&buffer)
+
+	records, values := readAllRecords(t, NewReader(&buffer), "b", "d")
+
+	equals(t, 4, len(records))
+	for i, exp := range []struct {
+		db       int
+		key      string
+		typ, enc string
+	}{
+		{0, "a", "string", "string"},
+		{0, "b", "zset", "skiplist"},
+		{2, "c", "set", "listpack"},
+		{2, "d", "hash", "hashtable"},
+	} {
+		equals(t, exp.db, records[i].DB)
+		equals(t, exp.key, DataToString(records[i].Key))
+		equals(t, exp.typ, records[i].Type)
+		equals(t, exp.enc, records[i].Encoding)
+	}
+
+	equals(t, DumpSortedSet{{Value: []byte("x"), Score: 1.5}}, values["b"])
+	equals(t, DumpHash{{Key: []byte("f"), Value: []byte("v")}}, values["d"])
+}
+
+func TestReaderValueNotAvailable(t *testing.T) {
+	var buffer bytes.Buffer
+	br := bufio.NewWriter(&buffer)
+	writeReaderTestRDB(br)
+	writeChecksum(&buffer)
+
+	r := NewReader(&buffer)
+
+	first, err := r.Next()
+	ok(t, err)
+
+	v, err := first.Value()
+	ok(t, err)
+	equals(t, DumpString("foo"), v)
+
+	// Already read
+	_, err = first.Value()
+	equals(t, ErrValueNotAvailable, err)
+
+	_, err = r.Next()
+	ok(t, err)
+
+	// Not the current record anymore
+	_, err = first.Value()
+	equals(t, ErrValueNotAvailable, err)
+
+	_, err = Record{}.Value()
+	equals(t, ErrValueNotAvailable, err)
+}
+
+func TestReaderInvalidChecksum(t *testing.T) {
+	var buffer bytes.Buffer
+	br := bufio.NewWriter(&buffer)
+	writeReaderTestRDB(br)
+	buffer.Write([]byte{1, 2, 3, 4, 5, 6, 7, 8})
+
+	r := NewReader(&buffer)
+	for i := 0; i < 4; i++ {
+		_, err := r.Next()
+		ok(t, err)
+	}
+
+	_, err := r.Next()
+	equals(t, ErrInvalidChecksum, err)
+
+	// The error is sticky
+	_, err = r.Next()
+	equals(t, ErrInvalidChecksum, err)
+}
+
+func TestReaderUnknownValueType(t *testing.T) {
+	var buffer bytes.Buffer
+	br := bufio.NewWriter(&buffer)
+	br.WriteString("REDIS0011")
+	br.WriteByte(0xFE) // next database byte
+	br.WriteByte(0)    // database number
+	br.WriteByte(8)    // unused type
+	writeString(br, "a")
+	br.Flush()
+
+	_, err := NewReader(&buffer).Next()
+	equals(t, ErrUnknownValueType, err)
+}
+
+func TestReaderExpireAtSnapshotTime(t *testing.T) {
+	var buffer bytes.Buffer
+	ctime := writeSnapshotTimeRDB(&buffer)
+
+	records, _ := readAllRecords(t, NewReader(&buffer, WithExpireAtSnapshotTime()))
+	equals(t, 1, len(records))
+	equals(t, ctime, records[0].Key.ExpiryReference)
+	equals(t, false, records[0].Key.Expired())
+
+	// Without the option, the key is evaluated against the current time
+	buffer.Reset()
+	writeSnapshotTimeRDB(&buffer)
+
+	records, _ = readAllRecords(t, NewReader(&buffer))
+	equals(t, true, records[0].Key.ExpiryReference.IsZero())
+	equals(t, true, records[0].Key.Expired())
+}
+
+// Skipping values must leave the reader at the same place as decoding them
+func TestReaderDumps(t *testing.T) {
+	paths, err := filepath.Glob("dumps/*.rdb")
+	ok(t, err)
+
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		ok(t, err)
+
+		skipped, _ := readAllRecords(t, NewReader(bytes.NewReader(data)))
+
+		var keys []string
+		for _, rec := range skipped {
+			keys = append(keys, DataToString(rec.Key))
+		}
+
+		decoded, values := readAllRecords(t, NewReader(bytes.NewReader(data)), keys...)
+		equals(t, len(skipped), len(decoded))
+		equals(t, len(keys), len(values))
+	}
+}
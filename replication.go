@@ -0,0 +1,201 @@
+package rdbtools
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// The length of the delimiter of a diskless transfer
+const replicationEOFMarkLen = 40
+
+// Represents the reply of a master starting a full resynchronization
+type FullResync struct {
+	ReplID string // The replication ID of the master
+	Offset int64  // The replication offset of the snapshot
+}
+
+// Represents an error reply of a master during the replication handshake
+type ReplicationError struct {
+	Command string
+	Message string
+}
+
+func (e ReplicationError) Error() string {
+	return fmt.Sprintf("replication: %s failed: %s", e.Command, e.Message)
+}
+
+// Connect to the master at addr as a replica and parse the RDB snapshot it sends with p.
+// The connection is closed once the snapshot is parsed.
+func ParseReplication(addr string, p Parser) (FullResync, error) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return FullResync{}, err
+	}
+	defer conn.Close()
+
+	resync, r, err := ReadReplicationSnapshot(conn)
+	if err != nil {
+		return resync, err
+	}
+
+	return resync, p.Parse(r)
+}
+
+// Perform the replication handshake (PING, REPLCONF and PSYNC ? -1) on conn and
+// return a reader of the RDB snapshot sent by the master.
+// Both the bulk and the diskless ($EOF:<mark>) transfers are supported.
+func ReadReplicationSnapshot(conn io.ReadWriter) (FullResync, io.Reader, error) {
+	br := bufio.NewReader(conn)
+
+	if _, err := replicationCommand(conn, br, "PING"); err != nil {
+		return FullResync{}, nil, err
+	}
+	if _, err := replicationCommand(conn, br, "REPLCONF", "capa", "eof", "capa", "psync2"); err != nil {
+		return FullResync{}, nil, err
+	}
+
+	reply, err := replicationCommand(conn, br, "PSYNC", "?", "-1")
+	if err != nil {
+		return FullResync{}, nil, err
+	}
+
+	resync, err := parseFullResync(reply)
+	if err != nil {
+		return resync, nil, err
+	}
+
+	line, err := readReplicationLine(br)
+	if err != nil {
+		return resync, nil, err
+	}
+	if len(line) < 2 || line[0] != '$' {
+		return resync, nil, ErrInvalidReplicationReply
+	}
+
+	if bytes.HasPrefix(line, []byte("$EOF:")) {
+		mark := line[5:]
+		if len(mark) != replicationEOFMarkLen {
+			return resync, nil, ErrInvalidReplicationReply
+		}
+		return resync, &eofMarkReader{r: br, mark: mark}, nil
+	}
+
+	l, err := strconv.ParseInt(string(line[1:]), 10, 64)
+	if err != nil || l < 0 {
+		return resync, nil, ErrInvalidReplicationReply
+	}
+
+	return resync, io.LimitReader(br, l), nil
+}
+
+// Send a command and read its status reply
+func replicationCommand(w io.Writer, r *bufio.Reader, args ...string) (string, error) {
+	var buf bytes.Buffer
+	buf.WriteString("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, arg := range args {
+		buf.WriteString("$" + strconv.Itoa(len(arg)) + "\r\n" + arg + "\r\n")
+	}
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return "", err
+	}
+
+	line, err := readReplicationLine(r)
+	if err != nil {
+		return "", err
+	}
+
+	switch {
+	case len(line) > 0 && line[0] == '+':
+		return string(line[1:]), nil
+	case len(line) > 0 && line[0] == '-':
+		return "", ReplicationError{Command: args[0], Message: string(line[1:])}
+	default:
+		return "", ErrInvalidReplicationReply
+	}
+}
+
+// Read a reply line, skipping the newlines sent by the master to keep the connection alive
+func readReplicationLine(r *bufio.Reader) ([]byte, error) {
+	for {
+		b, err := r.Peek(1)
+		if err != nil {
+			if err == io.EOF {
+				return nil, io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		if b[0] != '\n' {
+			break
+		}
+		r.ReadByte()
+	}
+
+	line, err := readAOFLine(r)
+	if err == io.EOF {
+		return nil, io.ErrUnexpectedEOF
+	}
+	if err == ErrInvalidAOFCommand {
+		return nil, ErrInvalidReplicationReply
+	}
+
+	return line, err
+}
+
+// Parse a reply of the form FULLRESYNC <replid> <offset>
+func parseFullResync(reply string) (FullResync, error) {
+	fields := strings.Fields(reply)
+	if len(fields) != 3 || fields[0] != "FULLRESYNC" {
+		return FullResync{}, ErrInvalidReplicationReply
+	}
+
+	offset, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil {
+		return FullResync{}, ErrInvalidReplicationReply
+	}
+
+	return FullResync{ReplID: fields[1], Offset: offset}, nil
+}
+
+// Reads a diskless transfer, which ends with a random mark instead of having a known length.
+// The buffered data is searched for the mark, its last bytes being held back until they
+// can't be the start of the mark.
+type eofMarkReader struct {
+	r    *bufio.Reader
+	mark []byte
+	eof  bool
+}
+
+func (e *eofMarkReader) Read(p []byte) (int, error) {
+	if e.eof {
+		return 0, io.EOF
+	}
+
+	// The transfer ends with the mark, so at least its length can be buffered
+	if _, err := e.r.Peek(len(e.mark)); err == io.EOF {
+		return 0, io.ErrUnexpectedEOF
+	} else if err != nil {
+		return 0, err
+	}
+
+	buf, _ := e.r.Peek(e.r.Buffered())
+
+	n := bytes.Index(buf, e.mark)
+	switch {
+	case n == 0:
+		e.eof = true
+		e.r.Discard(len(e.mark))
+		return 0, io.EOF
+	case n < 0:
+		n = len(buf) - len(e.mark) + 1
+	}
+
+	n = copy(p, buf[:n])
+	e.r.Discard(n)
+
+	return n, nil
+}
@@ -0,0 +1,189 @@
+package rdbtools
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+const testEOFMark = "0123456789abcdef0123456789abcdef01234567"
+
+// Serve the dump at path to a single replica, either as a bulk or as a diskless transfer.
+// If chunk isn't 0, the dump is written in chunks of this size to be read in several parts.
+func runFakeMaster(t *testing.T, path string, diskless bool, chunk int) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+		for _, reply := range []string{"+PONG\r\n", "+OK\r\n", "+FULLRESYNC 8de1787ba490483314a4d30f1c628bc5025eb761 42\r\n"} {
+			if _, err := readAOFCommand(r); err != nil {
+				t.Errorf("Error while reading command; err=%s", err)
+				return
+			}
+			io.WriteString(conn, reply)
+		}
+
+		// Keep alive newlines sent while the snapshot is generated
+		io.WriteString(conn, "\n\n")
+
+		if diskless {
+			io.WriteString(conn, "$EOF:"+testEOFMark+"\r\n")
+			writeChunks(conn, append(data, testEOFMark...), chunk)
+		} else {
+			io.WriteString(conn, "$"+strconv.Itoa(len(data))+"\r\n")
+			writeChunks(conn, data, chunk)
+		}
+
+		// The replication stream starts right after the snapshot
+		writeAOFCommand(conn, "PING")
+
+		io.Copy(io.Discard, r)
+	}()
+
+	return l.Addr().String()
+}
+
+// Write data in chunks of size, waiting a bit between them so that they are received separately
+func writeChunks(w io.Writer, data []byte, size int) {
+	if size == 0 {
+		w.Write(data)
+		return
+	}
+
+	for len(data) > 0 {
+		n := size
+		if n > len(data) {
+			n = len(data)
+		}
+		w.Write(data[:n])
+		data = data[n:]
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func testParseReplication(t *testing.T, diskless bool, chunk int) {
+	addr := runFakeMaster(t, "dumps/easily_compressible_string_key.rdb", diskless, chunk)
+
+	ctx := ParserContext{StringObjectCh: make(chan StringObject)}
+	p := NewParser(ctx)
+
+	done := make(chan FullResync, 1)
+	go func() {
+		resync, err := ParseReplication(addr, p)
+		if err != nil {
+			t.Errorf("Error while parsing; err=%s", err)
+		}
+		done <- resync
+	}()
+
+	var keys []string
+	for v := range ctx.StringObjectCh {
+		keys = append(keys, DataToString(v.Key))
+	}
+
+	equals(t, []string{strings.Repeat("a", 200)}, keys)
+	equals(t, FullResync{ReplID: "8de1787ba490483314a4d30f1c628bc5025eb761", Offset: 42}, <-done)
+}
+
+func TestParseReplication(t *testing.T) {
+	testParseReplication(t, false, 0)
+}
+
+func TestParseReplicationDiskless(t *testing.T) {
+	testParseReplication(t, true, 0)
+}
+
+func TestParseReplicationChunks(t *testing.T) {
+	testParseReplication(t, false, 3)
+}
+
+func TestParseReplicationDisklessChunks(t *testing.T) {
+	testParseReplication(t, true, 3)
+}
+
+// A connection replaying canned replies
+type fakeMasterConn struct {
+	io.Reader
+	bytes.Buffer
+}
+
+func (c *fakeMasterConn) Read(p []byte) (int, error) {
+	return c.Reader.Read(p)
+}
+
+func TestReadReplicationSnapshotErrorReply(t *testing.T) {
+	conn := &fakeMasterConn{Reader: strings.NewReader("+PONG\r\n-ERR unknown command\r\n")}
+
+	_, _, err := ReadReplicationSnapshot(conn)
+	equals(t, ReplicationError{Command: "REPLCONF", Message: "ERR unknown command"}, err)
+	equals(t, "replication: REPLCONF failed: ERR unknown command", err.Error())
+}
+
+func TestReadReplicationSnapshotInvalidReplies(t *testing.T) {
+	for _, s := range []string{
+		"PONG\r\n",
+		"+PONG\r\n+OK\r\n+CONTINUE\r\n",
+		"+PONG\r\n+OK\r\n+FULLRESYNC abc x\r\n",
+		"+PONG\r\n+OK\r\n+FULLRESYNC abc 0\r\n+OK\r\n",
+		"+PONG\r\n+OK\r\n+FULLRESYNC abc 0\r\n$-1\r\n",
+		"+PONG\r\n+OK\r\n+FULLRESYNC abc 0\r\n$EOF:abc\r\n",
+	} {
+		conn := &fakeMasterConn{Reader: strings.NewReader(s)}
+
+		_, _, err := ReadReplicationSnapshot(conn)
+		equals(t, ErrInvalidReplicationReply, err)
+	}
+
+	conn := &fakeMasterConn{Reader: strings.NewReader("+PONG\r\n+OK\r\n")}
+	_, _, err := ReadReplicationSnapshot(conn)
+	equals(t, io.ErrUnexpectedEOF, err)
+}
+
+func TestEOFMarkReader(t *testing.T) {
+	data := "REDIS0011" + testEOFMark[:10] + "foo"
+	r := &eofMarkReader{
+		r:    bufio.NewReader(strings.NewReader(data + testEOFMark + "*1\r\n")),
+		mark: []byte(testEOFMark),
+	}
+
+	b, err := io.ReadAll(r)
+	ok(t, err)
+	equals(t, data, string(b))
+
+	// Data read one byte at a time
+	r = &eofMarkReader{
+		r:    bufio.NewReader(iotest.OneByteReader(strings.NewReader(data + testEOFMark + "*1\r\n"))),
+		mark: []byte(testEOFMark),
+	}
+
+	b, err = io.ReadAll(r)
+	ok(t, err)
+	equals(t, data, string(b))
+
+	// Missing mark
+	r = &eofMarkReader{r: bufio.NewReader(strings.NewReader(data)), mark: []byte(testEOFMark)}
+	_, err = io.ReadAll(r)
+	equals(t, io.ErrUnexpectedEOF, err)
+}
@@ -28,18 +28,18 @@ func (p *parser) readSet(key KeyObject, r io.Reader) error {
 		return ErrUnexpectedEncodedLength
 	}
 
-	if p.ctx.SetMetadataCh != nil {
-		p.ctx.SetMetadataCh <- SetMetadata{Key: key, Len: l}
+	if err := p.h.StartSet(SetMetadata{Key: key, Len: int64(l)}); err != nil {
+		return err
 	}
 
-	for i := int64(0); i < l; i++ {
+	for i := uint64(0); i < l; i++ {
 		value, err := p.readString(r)
 		if err != nil {
 			return err
 		}
 
-		if p.ctx.SetDataCh != nil {
-			p.ctx.SetDataCh <- value
+		if err := p.h.SetMember(value); err != nil {
+			return err
 		}
 	}
 
@@ -47,12 +47,12 @@ func (p *parser) readSet(key KeyObject, r io.Reader) error {
 }
 
 func (p *parser) readIntSet(key KeyObject, r io.Reader) error {
-	data, err := p.readString(r)
+	data, err := p.readBytesString(r)
 	if err != nil {
 		return err
 	}
 
-	dr := bufio.NewReader(bytes.NewReader(data.([]byte)))
+	dr := bufio.NewReader(bytes.NewReader(data))
 
 	// read encoding (2, 4, 8 bytes per int)
 	var encoding uint32
@@ -66,8 +66,8 @@ func (p *parser) readIntSet(key KeyObject, r io.Reader) error {
 		return err
 	}
 
-	if p.ctx.SetMetadataCh != nil {
-		p.ctx.SetMetadataCh <- SetMetadata{Key: key, Len: int64(length)}
+	if err := p.h.StartSet(SetMetadata{Key: key, Len: int64(length)}); err != nil {
+		return err
 	}
 
 	// decode contents
@@ -94,10 +94,38 @@ func (p *parser) readIntSet(key KeyObject, r io.Reader) error {
 			e = i
 		}
 
-		if p.ctx.SetDataCh != nil {
-			p.ctx.SetDataCh <- e
+		if err := p.h.SetMember(e); err != nil {
+			return err
 		}
 	}
 
 	return nil
 }
+
+// Read a set encoded as a listpack (Redis >= 7.2)
+func (p *parser) readSetInListpack(key KeyObject, r io.Reader) error {
+	data, err := p.readBytesString(r)
+	if err != nil {
+		return err
+	}
+
+	onLenCallback := func(length int64) error {
+		if err := p.h.StartSet(SetMetadata{Key: key, Len: length}); err != nil {
+			return err
+		}
+		return nil
+	}
+	onElementCallback := func(e interface{}) error {
+		if err := p.h.SetMember(e); err != nil {
+			return err
+		}
+		return nil
+	}
+	dr := bufio.NewReader(bytes.NewReader(data))
+
+	if err := p.readListpack(dr, onLenCallback, onElementCallback); err != nil {
+		return err
+	}
+
+	return nil
+}
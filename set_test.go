@@ -3,6 +3,7 @@ package rdbtools
 import (
 	"bufio"
 	"bytes"
+	"encoding/binary"
 	"io"
 	"testing"
 )
@@ -26,7 +27,7 @@ func TestReadSet(t *testing.T) {
 		SetMetadataCh: make(chan SetMetadata),
 		SetDataCh:     make(chan interface{}),
 	}
-	p := &parser{ctx: ctx}
+	p := &parser{h: newChannelHandler(ctx)}
 
 	go readAndNotify(t, &buffer, "set", p.readSet)
 
@@ -75,10 +76,10 @@ func TestReadSetNoEntry(t *testing.T) {
 		SetMetadataCh: make(chan SetMetadata, 1),
 		SetDataCh:     make(chan interface{}, 1),
 	}
-	p := &parser{ctx: ctx}
+	p := &parser{h: newChannelHandler(ctx)}
 
 	go func() {
-		md := <-p.ctx.SetMetadataCh
+		md := <-ctx.SetMetadataCh
 		equals(t, "set", DataToString(md.Key))
 		equals(t, int64(1), md.Len)
 	}()
@@ -102,7 +103,7 @@ func TestReadInt16Set(t *testing.T) {
 		SetMetadataCh: make(chan SetMetadata),
 		SetDataCh:     make(chan interface{}),
 	}
-	p := parser{ctx: ctx}
+	p := parser{h: newChannelHandler(ctx)}
 
 	go readAndNotify(t, &buffer, "set", p.readIntSet)
 
@@ -135,7 +136,7 @@ func TestReadInt32Set(t *testing.T) {
 		SetMetadataCh: make(chan SetMetadata),
 		SetDataCh:     make(chan interface{}),
 	}
-	p := &parser{ctx: ctx}
+	p := &parser{h: newChannelHandler(ctx)}
 
 	go readAndNotify(t, &buffer, "set", p.readIntSet)
 
@@ -168,7 +169,7 @@ func TestReadInt64Set(t *testing.T) {
 		SetMetadataCh: make(chan SetMetadata),
 		SetDataCh:     make(chan interface{}),
 	}
-	p := &parser{ctx: ctx}
+	p := &parser{h: newChannelHandler(ctx)}
 
 	go readAndNotify(t, &buffer, "set", p.readIntSet)
 
@@ -235,7 +236,7 @@ func TestReadIntSetNoInt16Value(t *testing.T) {
 		SetMetadataCh: make(chan SetMetadata),
 		SetDataCh:     make(chan interface{}),
 	}
-	p := &parser{ctx: ctx}
+	p := &parser{h: newChannelHandler(ctx)}
 
 	go func() {
 		md := <-ctx.SetMetadataCh
@@ -261,7 +262,7 @@ func TestReadIntSetNoInt32Value(t *testing.T) {
 		SetMetadataCh: make(chan SetMetadata),
 		SetDataCh:     make(chan interface{}),
 	}
-	p := &parser{ctx: ctx}
+	p := &parser{h: newChannelHandler(ctx)}
 
 	go func() {
 		md := <-ctx.SetMetadataCh
@@ -287,7 +288,7 @@ func TestReadIntSetNoInt64Value(t *testing.T) {
 		SetMetadataCh: make(chan SetMetadata),
 		SetDataCh:     make(chan interface{}),
 	}
-	p := &parser{ctx: ctx}
+	p := &parser{h: newChannelHandler(ctx)}
 
 	go func() {
 		md := <-ctx.SetMetadataCh
@@ -298,3 +299,59 @@ func TestReadIntSetNoInt64Value(t *testing.T) {
 	err := p.readIntSet(KeyObject{Key: []byte("set")}, bufio.NewReader(&buffer))
 	equals(t, io.EOF, err)
 }
+
+func TestReadSetInListpack(t *testing.T) {
+	var buffer bytes.Buffer
+
+	br := bufio.NewWriter(&buffer)
+	lp := newListpack("a", int64(1))
+	br.WriteByte(byte(len(lp))) // string length
+	br.Write(lp)
+	br.Flush()
+
+	ctx := ParserContext{
+		SetMetadataCh: make(chan SetMetadata),
+		SetDataCh:     make(chan interface{}),
+	}
+	p := parser{h: newChannelHandler(ctx)}
+
+	go readAndNotify(t, &buffer, "set", p.readSetInListpack)
+
+	var elements []interface{}
+	stop := false
+	for !stop {
+		select {
+		case md := <-ctx.SetMetadataCh:
+			equals(t, "set", DataToString(md.Key))
+			equals(t, int64(2), md.Len)
+		case d := <-ctx.SetDataCh:
+			elements = append(elements, d)
+		case <-end:
+			stop = true
+		}
+	}
+
+	equals(t, []interface{}{[]byte("a"), int64(1)}, elements)
+}
+
+func TestReadSetInListpackNoData(t *testing.T) {
+	var buffer bytes.Buffer
+
+	p := &parser{}
+	err := p.readSetInListpack(KeyObject{Key: []byte("set")}, bufio.NewReader(&buffer))
+	equals(t, io.EOF, err)
+}
+
+func TestReadSetInListpackNoElement(t *testing.T) {
+	var buffer bytes.Buffer
+
+	br := bufio.NewWriter(&buffer)
+	br.WriteByte(6)
+	binary.Write(br, binary.LittleEndian, uint32(0))
+	binary.Write(br, binary.LittleEndian, uint16(0))
+	br.Flush()
+
+	p := &parser{h: NopHandler{}}
+	err := p.readSetInListpack(KeyObject{Key: []byte("set")}, bufio.NewReader(&buffer))
+	equals(t, io.EOF, err)
+}
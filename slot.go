@@ -0,0 +1,87 @@
+package rdbtools
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// The number of hash slots of a Redis Cluster
+const ClusterSlots = 16384
+
+// Represents the number of keys of a cluster hash slot in the database being read (Redis >= 7.4)
+type SlotInfo struct {
+	Slot       int
+	Len        int64 // Number of keys in the slot
+	ExpiresLen int64 // Number of keys with an expiry time in the slot
+}
+
+// Returns a visualization of the slot info
+func (s SlotInfo) String() string {
+	return fmt.Sprintf("SlotInfo{Slot: %d, Len: %d, ExpiresLen: %d}", s.Slot, s.Len, s.ExpiresLen)
+}
+
+func (p *parser) readSlotInfo(r io.Reader) error {
+	var values [3]uint64
+	for i := range values {
+		v, e, err := p.readLen(r)
+		if err != nil {
+			return err
+		}
+		if e {
+			return ErrUnexpectedEncodedLength
+		}
+		values[i] = v
+	}
+
+	if err := p.h.SlotInfo(SlotInfo{Slot: int(values[0]), Len: int64(values[1]), ExpiresLen: int64(values[2])}); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Returns the cluster hash slot of a key.
+//
+// If the key contains a non empty hash tag, that is a substring between the first { and the following },
+// only the hash tag is hashed.
+func KeySlot(key []byte) int {
+	if start := bytes.IndexByte(key, '{'); start >= 0 {
+		if end := bytes.IndexByte(key[start+1:], '}'); end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+
+	return int(crc16(key) & (ClusterSlots - 1))
+}
+
+// Returns the cluster hash slot of a key read by the parser, either a byte slice or an integer
+func keyObjectSlot(key interface{}) int {
+	switch v := key.(type) {
+	case []byte:
+		return KeySlot(v)
+	case string:
+		return KeySlot([]byte(v))
+	case int8, int16, int32, int64:
+		return KeySlot([]byte(DataToString(v)))
+	default:
+		return 0
+	}
+}
+
+// CRC16 XMODEM, as used by Redis Cluster
+func crc16(data []byte) uint16 {
+	var crc uint16
+	for _, b := range data {
+		crc ^= uint16(b) << 8
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+
+	return crc
+}
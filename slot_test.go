@@ -0,0 +1,76 @@
+package rdbtools
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestSlotInfoString(t *testing.T) {
+	s := SlotInfo{Slot: 12182, Len: 10, ExpiresLen: 2}
+	equals(t, "SlotInfo{Slot: 12182, Len: 10, ExpiresLen: 2}", s.String())
+}
+
+func TestReadSlotInfo(t *testing.T) {
+	var buffer bytes.Buffer
+
+	br := bufio.NewWriter(&buffer)
+	writeLen(br, 12182)
+	writeLen(br, 10)
+	writeLen(br, 2)
+	br.Flush()
+
+	ctx := ParserContext{SlotInfoCh: make(chan SlotInfo)}
+	p := &parser{h: newChannelHandler(ctx)}
+
+	go func() {
+		s := <-ctx.SlotInfoCh
+		equals(t, SlotInfo{Slot: 12182, Len: 10, ExpiresLen: 2}, s)
+	}()
+
+	err := p.readSlotInfo(bufio.NewReader(&buffer))
+	ok(t, err)
+}
+
+func TestReadSlotInfoNoData(t *testing.T) {
+	var buffer bytes.Buffer
+	buffer.Write([]byte{1, 2})
+
+	p := &parser{}
+	err := p.readSlotInfo(bufio.NewReader(&buffer))
+	equals(t, io.EOF, err)
+}
+
+func TestReadSlotInfoEncodedLen(t *testing.T) {
+	var buffer bytes.Buffer
+	buffer.Write([]byte{1, 0xC0})
+
+	p := &parser{}
+	err := p.readSlotInfo(bufio.NewReader(&buffer))
+	equals(t, ErrUnexpectedEncodedLength, err)
+}
+
+func TestCRC16(t *testing.T) {
+	equals(t, uint16(0x31C3), crc16([]byte("123456789")))
+	equals(t, uint16(0), crc16(nil))
+}
+
+func TestKeySlot(t *testing.T) {
+	equals(t, 12182, KeySlot([]byte("foo")))
+	equals(t, 5061, KeySlot([]byte("bar")))
+	equals(t, 11058, KeySlot([]byte("somekey")))
+
+	// Hash tags
+	equals(t, KeySlot([]byte("user1000")), KeySlot([]byte("{user1000}.following")))
+	equals(t, KeySlot([]byte("bar")), KeySlot([]byte("foo{bar}{zap}")))
+	equals(t, KeySlot([]byte("{bar")), KeySlot([]byte("foo{{bar}}")))
+	equals(t, KeySlot([]byte("foo{}{bar}")), int(crc16([]byte("foo{}{bar}"))&(ClusterSlots-1)))
+	equals(t, KeySlot([]byte("foo{bar")), int(crc16([]byte("foo{bar"))&(ClusterSlots-1)))
+}
+
+func TestKeyObjectSlot(t *testing.T) {
+	equals(t, 12182, NewKeyObject([]byte("foo"), -1).Slot)
+	equals(t, 8000, NewKeyObject(int8(42), -1).Slot)
+	equals(t, KeySlot([]byte("42")), NewKeyObject(int32(42), -1).Slot)
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"strconv"
+	"time"
 )
 
 // Represents the metadata of a sorted set, which is the key and the sorted set length
@@ -21,12 +22,17 @@ func (m SortedSetMetadata) String() string {
 
 // Represents an entry in a sorted set.
 type SortedSetEntry struct {
-	Value interface{}
-	Score float64
+	Value      interface{}
+	Score      float64
+	ExpiryTime time.Time // The expiry time of the member (KeyDB, see WithKeyDBMemberExpiries). If none, this object IsZero() method will return true
 }
 
 // Returns a visualization of a sorted set entry
 func (e SortedSetEntry) String() string {
+	if !e.ExpiryTime.IsZero() {
+		return fmt.Sprintf("SortedSetEntry{Value: %s, Score: %0.4f, ExpiryTime: %s}", DataToString(e.Value), e.Score, e.ExpiryTime)
+	}
+
 	return fmt.Sprintf("SortedSetEntry{Value: %s, Score: %0.4f}", DataToString(e.Value), e.Score)
 }
 
@@ -39,9 +45,11 @@ func (p *parser) readSortedSet(key KeyObject, r io.Reader) error {
 		return ErrUnexpectedEncodedLength
 	}
 
-	p.ctx.SortedSetMetadataCh <- SortedSetMetadata{Key: key, Len: l}
+	if err := p.h.StartSortedSet(SortedSetMetadata{Key: key, Len: int64(l)}); err != nil {
+		return err
+	}
 
-	for i := int64(0); i < l; i++ {
+	for i := uint64(0); i < l; i++ {
 		value, err := p.readString(r)
 		if err != nil {
 			return err
@@ -53,53 +61,78 @@ func (p *parser) readSortedSet(key KeyObject, r io.Reader) error {
 		}
 
 		e := SortedSetEntry{Value: value, Score: score}
-		p.ctx.SortedSetEntriesCh <- e
+		if err := p.h.SortedSetEntry(e); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Read a sorted set whose scores are binary doubles (RDB version >= 8)
+func (p *parser) readSortedSet2(key KeyObject, r io.Reader) error {
+	l, e, err := p.readLen(r)
+	if err != nil {
+		return err
+	}
+	if e {
+		return ErrUnexpectedEncodedLength
+	}
+
+	if err := p.h.StartSortedSet(SortedSetMetadata{Key: key, Len: int64(l)}); err != nil {
+		return err
+	}
+
+	for i := uint64(0); i < l; i++ {
+		value, err := p.readString(r)
+		if err != nil {
+			return err
+		}
+
+		score, err := readBinaryDoubleValue(r)
+		if err != nil {
+			return err
+		}
+
+		if err := p.h.SortedSetEntry(SortedSetEntry{Value: value, Score: score}); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
 func (p *parser) readSortedSetInZipList(key KeyObject, r io.Reader) error {
-	data, err := p.readString(r)
+	data, err := p.readBytesString(r)
 	if err != nil {
 		return err
 	}
 
 	var el interface{} = nil
 	onLenCallback := func(length int64) error {
-		p.ctx.SortedSetMetadataCh <- SortedSetMetadata{Key: key, Len: length / 2}
+		if err := p.h.StartSortedSet(SortedSetMetadata{Key: key, Len: length / 2}); err != nil {
+			return err
+		}
 		return nil
 	}
 	onElementCallback := func(e interface{}) error {
 		if el == nil {
 			el = e
 		} else {
-			var score float64
-			switch v := e.(type) {
-			case []byte:
-				score, err = strconv.ParseFloat(string(v), 64)
-				if err != nil {
-					return err
-				}
-			case int8:
-				score = float64(v)
-			case int:
-				score = float64(v)
-			case int16:
-				score = float64(v)
-			case int32:
-				score = float64(v)
-			case int64:
-				score = float64(v)
+			score, err := elementToScore(e)
+			if err != nil {
+				return err
 			}
 
-			p.ctx.SortedSetEntriesCh <- SortedSetEntry{Value: el, Score: score}
+			if err := p.h.SortedSetEntry(SortedSetEntry{Value: el, Score: score}); err != nil {
+				return err
+			}
 			el = nil
 		}
 
 		return nil
 	}
-	dr := bufio.NewReader(bytes.NewReader(data.([]byte)))
+	dr := bufio.NewReader(bytes.NewReader(data))
 
 	if err := p.readZipList(dr, onLenCallback, onElementCallback); err != nil {
 		return err
@@ -107,3 +140,63 @@ func (p *parser) readSortedSetInZipList(key KeyObject, r io.Reader) error {
 
 	return nil
 }
+
+// Read a sorted set encoded as a listpack (Redis >= 7.0)
+func (p *parser) readSortedSetInListpack(key KeyObject, r io.Reader) error {
+	data, err := p.readBytesString(r)
+	if err != nil {
+		return err
+	}
+
+	var el interface{} = nil
+	onLenCallback := func(length int64) error {
+		if err := p.h.StartSortedSet(SortedSetMetadata{Key: key, Len: length / 2}); err != nil {
+			return err
+		}
+		return nil
+	}
+	onElementCallback := func(e interface{}) error {
+		if el == nil {
+			el = e
+		} else {
+			score, err := elementToScore(e)
+			if err != nil {
+				return err
+			}
+
+			if err := p.h.SortedSetEntry(SortedSetEntry{Value: el, Score: score}); err != nil {
+				return err
+			}
+			el = nil
+		}
+
+		return nil
+	}
+	dr := bufio.NewReader(bytes.NewReader(data))
+
+	if err := p.readListpack(dr, onLenCallback, onElementCallback); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Convert a zip list or listpack element to a sorted set score
+func elementToScore(e interface{}) (float64, error) {
+	switch v := e.(type) {
+	case []byte:
+		return strconv.ParseFloat(string(v), 64)
+	case int8:
+		return float64(v), nil
+	case int:
+		return float64(v), nil
+	case int16:
+		return float64(v), nil
+	case int32:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	}
+
+	return 0, nil
+}
@@ -3,7 +3,9 @@ package rdbtools
 import (
 	"bufio"
 	"bytes"
+	"encoding/binary"
 	"io"
+	"math"
 	"testing"
 )
 
@@ -31,7 +33,7 @@ func TestReadSortedSet(t *testing.T) {
 		SortedSetMetadataCh: make(chan SortedSetMetadata),
 		SortedSetEntriesCh:  make(chan SortedSetEntry),
 	}
-	p := &parser{ctx: ctx}
+	p := &parser{h: newChannelHandler(ctx)}
 
 	go readAndNotify(t, &buffer, "zset", p.readSortedSet)
 
@@ -92,7 +94,7 @@ func TestReadSortedSetNoEntryKey(t *testing.T) {
 		SortedSetMetadataCh: make(chan SortedSetMetadata),
 		SortedSetEntriesCh:  make(chan SortedSetEntry),
 	}
-	p := &parser{ctx: ctx}
+	p := &parser{h: newChannelHandler(ctx)}
 
 	go func() {
 		md := <-ctx.SortedSetMetadataCh
@@ -117,7 +119,7 @@ func TestReadSortedSetNoEntryScore(t *testing.T) {
 		SortedSetMetadataCh: make(chan SortedSetMetadata),
 		SortedSetEntriesCh:  make(chan SortedSetEntry),
 	}
-	p := &parser{ctx: ctx}
+	p := &parser{h: newChannelHandler(ctx)}
 
 	go func() {
 		md := <-ctx.SortedSetMetadataCh
@@ -129,6 +131,82 @@ func TestReadSortedSetNoEntryScore(t *testing.T) {
 	equals(t, io.EOF, err)
 }
 
+func TestReadSortedSet2(t *testing.T) {
+	var buffer bytes.Buffer
+	br := bufio.NewWriter(&buffer)
+
+	br.WriteByte(2) // Sorted set len
+	br.WriteByte(3) // Entry key len
+	br.WriteString("bar")
+	binary.Write(br, binary.LittleEndian, 20.1)
+	br.WriteByte(6) // Entry key len
+	br.WriteString("foobar")
+	binary.Write(br, binary.LittleEndian, math.Inf(-1))
+	br.Flush()
+
+	ctx := ParserContext{
+		SortedSetMetadataCh: make(chan SortedSetMetadata),
+		SortedSetEntriesCh:  make(chan SortedSetEntry),
+	}
+	p := &parser{h: newChannelHandler(ctx)}
+
+	go readAndNotify(t, &buffer, "zset", p.readSortedSet2)
+
+	var entries []SortedSetEntry
+	stop := false
+	for !stop {
+		select {
+		case md := <-ctx.SortedSetMetadataCh:
+			equals(t, "zset", DataToString(md.Key))
+			equals(t, int64(2), md.Len)
+		case d := <-ctx.SortedSetEntriesCh:
+			entries = append(entries, d)
+		case <-end:
+			stop = true
+		}
+	}
+
+	equals(t, []SortedSetEntry{
+		{Value: []byte("bar"), Score: 20.1},
+		{Value: []byte("foobar"), Score: math.Inf(-1)},
+	}, entries)
+}
+
+func TestReadSortedSet2NoData(t *testing.T) {
+	var buffer bytes.Buffer
+
+	p := &parser{}
+	err := p.readSortedSet2(KeyObject{Key: []byte("zset")}, bufio.NewReader(&buffer))
+	equals(t, io.EOF, err)
+}
+
+func TestReadSortedSet2EncodedLen(t *testing.T) {
+	var buffer bytes.Buffer
+
+	br := bufio.NewWriter(&buffer)
+	br.WriteByte(0xC0)
+	br.Flush()
+
+	p := &parser{}
+	err := p.readSortedSet2(KeyObject{Key: []byte("zset")}, bufio.NewReader(&buffer))
+	equals(t, ErrUnexpectedEncodedLength, err)
+}
+
+func TestReadSortedSet2NoEntryScore(t *testing.T) {
+	var buffer bytes.Buffer
+
+	br := bufio.NewWriter(&buffer)
+	br.WriteByte(1)
+	br.WriteByte(1)
+	br.WriteByte('a')
+	br.Write([]byte{0, 0, 0, 0})
+	br.Flush()
+
+	p := &parser{h: NopHandler{}}
+	err := p.readSortedSet2(KeyObject{Key: []byte("zset")}, bufio.NewReader(&buffer))
+	equals(t, io.ErrUnexpectedEOF, err)
+}
+
 func TestReadSortedSetInZipList(t *testing.T) {
 	var buffer bytes.Buffer
 	br := bufio.NewWriter(&buffer)
@@ -149,7 +227,7 @@ func TestReadSortedSetInZipList(t *testing.T) {
 		SortedSetMetadataCh: make(chan SortedSetMetadata),
 		SortedSetEntriesCh:  make(chan SortedSetEntry),
 	}
-	p := &parser{ctx: ctx}
+	p := &parser{h: newChannelHandler(ctx)}
 
 	go readAndNotify(t, &buffer, "zset", p.readSortedSetInZipList)
 
@@ -213,7 +291,7 @@ func TestReadSortedSetInZipListIntScore(t *testing.T) {
 		SortedSetMetadataCh: make(chan SortedSetMetadata),
 		SortedSetEntriesCh:  make(chan SortedSetEntry),
 	}
-	p := &parser{ctx: ctx}
+	p := &parser{h: newChannelHandler(ctx)}
 
 	go readAndNotify(t, &buffer, "zset", p.readSortedSetInZipList)
 
@@ -281,7 +359,7 @@ func TestReadSortedSetInZipListWrongScore(t *testing.T) {
 		SortedSetMetadataCh: make(chan SortedSetMetadata),
 		SortedSetEntriesCh:  make(chan SortedSetEntry),
 	}
-	p := &parser{ctx: ctx}
+	p := &parser{h: newChannelHandler(ctx)}
 
 	go func() {
 		md := <-ctx.SortedSetMetadataCh
@@ -292,3 +370,63 @@ func TestReadSortedSetInZipListWrongScore(t *testing.T) {
 	err := p.readSortedSetInZipList(KeyObject{Key: []byte("zset")}, bufio.NewReader(&buffer))
 	equals(t, "strconv.ParseFloat: parsing \"foobar\": invalid syntax", err.Error())
 }
+
+func TestReadSortedSetInListpack(t *testing.T) {
+	var buffer bytes.Buffer
+	br := bufio.NewWriter(&buffer)
+
+	lp := newListpack("foo", "1.5", "bar", int64(-2))
+	br.WriteByte(byte(len(lp))) // String length
+	br.Write(lp)
+	br.Flush()
+
+	ctx := ParserContext{
+		SortedSetMetadataCh: make(chan SortedSetMetadata),
+		SortedSetEntriesCh:  make(chan SortedSetEntry),
+	}
+	p := &parser{h: newChannelHandler(ctx)}
+
+	go readAndNotify(t, &buffer, "zset", p.readSortedSetInListpack)
+
+	var entries []SortedSetEntry
+	stop := false
+	for !stop {
+		select {
+		case md := <-ctx.SortedSetMetadataCh:
+			equals(t, "zset", DataToString(md.Key))
+			equals(t, int64(2), md.Len)
+		case e := <-ctx.SortedSetEntriesCh:
+			entries = append(entries, e)
+		case <-end:
+			stop = true
+		}
+	}
+
+	equals(t, 2, len(entries))
+	equals(t, "foo", DataToString(entries[0].Value))
+	equals(t, 1.5, entries[0].Score)
+	equals(t, "bar", DataToString(entries[1].Value))
+	equals(t, -2.0, entries[1].Score)
+}
+
+func TestReadSortedSetInListpackNoData(t *testing.T) {
+	var buffer bytes.Buffer
+
+	p := &parser{}
+	err := p.readSortedSetInListpack(KeyObject{Key: []byte("zset")}, bufio.NewReader(&buffer))
+	equals(t, io.EOF, err)
+}
+
+func TestReadSortedSetInListpackWrongScore(t *testing.T) {
+	var buffer bytes.Buffer
+
+	br := bufio.NewWriter(&buffer)
+	lp := newListpack("foobar", "foobar")
+	br.WriteByte(byte(len(lp)))
+	br.Write(lp)
+	br.Flush()
+
+	p := &parser{h: NopHandler{}}
+	err := p.readSortedSetInListpack(KeyObject{Key: []byte("zset")}, bufio.NewReader(&buffer))
+	equals(t, "strconv.ParseFloat: parsing \"foobar\": invalid syntax", err.Error())
+}
@@ -0,0 +1,504 @@
+package rdbtools
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Represents the ID of a stream entry
+type StreamID struct {
+	Ms  uint64
+	Seq uint64
+}
+
+// Returns a visualization of the stream ID, as used by Redis
+func (id StreamID) String() string {
+	return fmt.Sprintf("%d-%d", id.Ms, id.Seq)
+}
+
+// Represents the metadata of a stream
+type StreamMetadata struct {
+	Key    KeyObject
+	Len    int64 // Number of entries in the stream
+	LastID StreamID
+
+	// The following fields are only available since Redis 7.0
+	FirstID      StreamID
+	MaxDeletedID StreamID
+	EntriesAdded int64 // -1 if not available
+}
+
+// Returns a visualization of the stream metadata
+func (m StreamMetadata) String() string {
+	return fmt.Sprintf("StreamMetadata{Key: %s, Len: %d, LastID: %s}", DataToString(m.Key), m.Len, m.LastID)
+}
+
+// Represents a field of a stream entry
+type StreamField struct {
+	Name  interface{}
+	Value interface{}
+}
+
+// Represents an entry of a stream
+type StreamEntry struct {
+	ID     StreamID
+	Fields []StreamField
+}
+
+// Returns a visualization of the stream entry
+func (e StreamEntry) String() string {
+	return fmt.Sprintf("StreamEntry{ID: %s, Fields: %d}", e.ID, len(e.Fields))
+}
+
+// Represents a message delivered to a consumer group but not yet acknowledged
+type StreamPendingEntry struct {
+	ID            StreamID
+	DeliveryTime  time.Time
+	DeliveryCount int64
+}
+
+// Represents a consumer of a consumer group
+type StreamConsumer struct {
+	Name       interface{}
+	SeenTime   time.Time
+	ActiveTime time.Time  // Only available since Redis 7.2, IsZero() returns true otherwise
+	Pending    []StreamID // IDs of the pending entries owned by the consumer
+}
+
+// Represents a consumer group of a stream
+type StreamConsumerGroup struct {
+	Name        interface{}
+	LastID      StreamID
+	EntriesRead int64 // Only available since Redis 7.0, -1 if not available
+	Pending     []StreamPendingEntry
+	Consumers   []StreamConsumer
+}
+
+// Returns a visualization of the consumer group
+func (g StreamConsumerGroup) String() string {
+	return fmt.Sprintf("StreamConsumerGroup{Name: %s, LastID: %s, Pending: %d, Consumers: %d}", DataToString(g.Name), g.LastID, len(g.Pending), len(g.Consumers))
+}
+
+const (
+	streamItemFlagDeleted    = 1 << 0
+	streamItemFlagSameFields = 1 << 1
+)
+
+type streamNode struct {
+	master StreamID
+	data   []byte
+}
+
+// Read a stream (Redis >= 5.0). version is 1 for RDB type 15, 2 for type 19 and 3 for type 21.
+//
+// A stream is stored as a radix tree of listpacks keyed by the ID of their first (master) entry,
+// followed by the stream metadata and the consumer groups.
+func (p *parser) readStream(key KeyObject, r io.Reader, version int) error {
+	l, e, err := p.readLen(r)
+	if err != nil {
+		return err
+	}
+	if e {
+		return ErrUnexpectedEncodedLength
+	}
+
+	// The metadata comes after the entries, so we buffer the listpacks
+	// to send the metadata first.
+	nodes := make([]streamNode, 0)
+	for i := uint64(0); i < l; i++ {
+		nodeKey, err := p.readString(r)
+		if err != nil {
+			return err
+		}
+
+		b, ok := nodeKey.([]byte)
+		if !ok || len(b) != 16 {
+			return ErrInvalidStreamID
+		}
+
+		data, err := p.readBytesString(r)
+		if err != nil {
+			return err
+		}
+
+		nodes = append(nodes, streamNode{master: rawToStreamID(b), data: data})
+	}
+
+	md := StreamMetadata{Key: key, EntriesAdded: -1}
+
+	length, e, err := p.readLen(r)
+	if err != nil {
+		return err
+	}
+	if e {
+		return ErrUnexpectedEncodedLength
+	}
+	md.Len = int64(length)
+
+	if md.LastID, err = p.readStreamID(r); err != nil {
+		return err
+	}
+
+	if version >= 2 {
+		if md.FirstID, err = p.readStreamID(r); err != nil {
+			return err
+		}
+
+		if md.MaxDeletedID, err = p.readStreamID(r); err != nil {
+			return err
+		}
+
+		entriesAdded, e, err := p.readLen(r)
+		if err != nil {
+			return err
+		}
+		if e {
+			return ErrUnexpectedEncodedLength
+		}
+		md.EntriesAdded = int64(entriesAdded)
+	}
+
+	if err := p.h.StartStream(md); err != nil {
+		return err
+	}
+
+	for _, node := range nodes {
+		if err := p.readStreamListpack(node); err != nil {
+			return err
+		}
+	}
+
+	groups, e, err := p.readLen(r)
+	if err != nil {
+		return err
+	}
+	if e {
+		return ErrUnexpectedEncodedLength
+	}
+
+	for i := uint64(0); i < groups; i++ {
+		group, err := p.readStreamConsumerGroup(r, version)
+		if err != nil {
+			return err
+		}
+
+		if err := p.h.StreamConsumerGroup(group); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Read the entries of a stream listpack.
+//
+// The first entry of the listpack is the master entry, which holds the entry counts
+// and the field names shared by the entries flagged with streamItemFlagSameFields:
+//
+//	count | deleted | num-fields | field 1 | ... | field N | 0
+//
+// The other entries follow, with an ID relative to the master ID:
+//
+//	flags | ms-diff | seq-diff | num-fields | field 1 | value 1 | ... | lp-count
+//
+// num-fields and the field names are omitted if the entry has the same fields as the master entry.
+func (p *parser) readStreamListpack(node streamNode) error {
+	it := &streamListpackIterator{elements: make([]interface{}, 0)}
+
+	onLenCallback := func(length int64) error {
+		return nil
+	}
+	onElementCallback := func(e interface{}) error {
+		it.elements = append(it.elements, e)
+		return nil
+	}
+	dr := bufio.NewReader(bytes.NewReader(node.data))
+
+	if err := p.readListpack(dr, onLenCallback, onElementCallback); err != nil {
+		return err
+	}
+
+	if len(it.elements) == 0 {
+		return nil
+	}
+
+	count, err := it.nextInt()
+	if err != nil {
+		return err
+	}
+
+	deleted, err := it.nextInt()
+	if err != nil {
+		return err
+	}
+
+	masterFieldsCount, err := it.nextInt()
+	if err != nil {
+		return err
+	}
+	if masterFieldsCount < 0 || masterFieldsCount > it.remaining() {
+		return ErrInvalidStreamListpack
+	}
+
+	masterFields := make([]interface{}, masterFieldsCount)
+	for i := range masterFields {
+		if masterFields[i], err = it.next(); err != nil {
+			return err
+		}
+	}
+
+	// The master entry terminator
+	if _, err := it.next(); err != nil {
+		return err
+	}
+
+	for i := int64(0); i < count+deleted; i++ {
+		flags, err := it.nextInt()
+		if err != nil {
+			return err
+		}
+
+		msDiff, err := it.nextInt()
+		if err != nil {
+			return err
+		}
+
+		seqDiff, err := it.nextInt()
+		if err != nil {
+			return err
+		}
+
+		entry := StreamEntry{
+			ID: StreamID{Ms: node.master.Ms + uint64(msDiff), Seq: node.master.Seq + uint64(seqDiff)},
+		}
+
+		if flags&streamItemFlagSameFields != 0 {
+			entry.Fields = make([]StreamField, masterFieldsCount)
+			for j := range entry.Fields {
+				entry.Fields[j].Name = masterFields[j]
+				if entry.Fields[j].Value, err = it.next(); err != nil {
+					return err
+				}
+			}
+		} else {
+			fieldsCount, err := it.nextInt()
+			if err != nil {
+				return err
+			}
+			if fieldsCount < 0 || fieldsCount > it.remaining()/2 {
+				return ErrInvalidStreamListpack
+			}
+
+			entry.Fields = make([]StreamField, fieldsCount)
+			for j := range entry.Fields {
+				if entry.Fields[j].Name, err = it.next(); err != nil {
+					return err
+				}
+				if entry.Fields[j].Value, err = it.next(); err != nil {
+					return err
+				}
+			}
+		}
+
+		// lp-count
+		if _, err := it.next(); err != nil {
+			return err
+		}
+
+		if flags&streamItemFlagDeleted != 0 {
+			continue
+		}
+
+		if err := p.h.StreamEntry(entry); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *parser) readStreamConsumerGroup(r io.Reader, version int) (StreamConsumerGroup, error) {
+	var err error
+	group := StreamConsumerGroup{EntriesRead: -1}
+
+	if group.Name, err = p.readString(r); err != nil {
+		return group, err
+	}
+
+	if group.LastID, err = p.readStreamID(r); err != nil {
+		return group, err
+	}
+
+	if version >= 2 {
+		entriesRead, e, err := p.readLen(r)
+		if err != nil {
+			return group, err
+		}
+		if e {
+			return group, ErrUnexpectedEncodedLength
+		}
+		group.EntriesRead = int64(entriesRead)
+	}
+
+	// Global pending entries list of the group
+	l, e, err := p.readLen(r)
+	if err != nil {
+		return group, err
+	}
+	if e {
+		return group, ErrUnexpectedEncodedLength
+	}
+
+	// The lengths aren't trusted to allocate the slices upfront, a bogus one would exhaust the memory
+	// instead of failing when the data runs out.
+	group.Pending = make([]StreamPendingEntry, 0)
+	for i := uint64(0); i < l; i++ {
+		var pe StreamPendingEntry
+
+		if pe.ID, err = readRawStreamID(r); err != nil {
+			return group, err
+		}
+
+		if pe.DeliveryTime, err = readMillisecondTime(r); err != nil {
+			return group, err
+		}
+
+		count, e, err := p.readLen(r)
+		if err != nil {
+			return group, err
+		}
+		if e {
+			return group, ErrUnexpectedEncodedLength
+		}
+		pe.DeliveryCount = int64(count)
+
+		group.Pending = append(group.Pending, pe)
+	}
+
+	// Consumers
+	l, e, err = p.readLen(r)
+	if err != nil {
+		return group, err
+	}
+	if e {
+		return group, ErrUnexpectedEncodedLength
+	}
+
+	group.Consumers = make([]StreamConsumer, 0)
+	for i := uint64(0); i < l; i++ {
+		var c StreamConsumer
+
+		if c.Name, err = p.readString(r); err != nil {
+			return group, err
+		}
+
+		if c.SeenTime, err = readMillisecondTime(r); err != nil {
+			return group, err
+		}
+
+		if version >= 3 {
+			if c.ActiveTime, err = readMillisecondTime(r); err != nil {
+				return group, err
+			}
+		}
+
+		pl, e, err := p.readLen(r)
+		if err != nil {
+			return group, err
+		}
+		if e {
+			return group, ErrUnexpectedEncodedLength
+		}
+
+		c.Pending = make([]StreamID, 0)
+		for j := uint64(0); j < pl; j++ {
+			id, err := readRawStreamID(r)
+			if err != nil {
+				return group, err
+			}
+			c.Pending = append(c.Pending, id)
+		}
+
+		group.Consumers = append(group.Consumers, c)
+	}
+
+	return group, nil
+}
+
+// Read a stream ID stored as two lengths
+func (p *parser) readStreamID(r io.Reader) (StreamID, error) {
+	ms, e, err := p.readLen(r)
+	if err != nil {
+		return StreamID{}, err
+	}
+	if e {
+		return StreamID{}, ErrUnexpectedEncodedLength
+	}
+
+	seq, e, err := p.readLen(r)
+	if err != nil {
+		return StreamID{}, err
+	}
+	if e {
+		return StreamID{}, ErrUnexpectedEncodedLength
+	}
+
+	return StreamID{Ms: ms, Seq: seq}, nil
+}
+
+// Read a stream ID stored as 16 raw bytes
+func readRawStreamID(r io.Reader) (StreamID, error) {
+	b, err := readBytes(r, 16)
+	if err != nil {
+		return StreamID{}, err
+	}
+
+	return rawToStreamID(b), nil
+}
+
+func rawToStreamID(b []byte) StreamID {
+	return StreamID{
+		Ms:  binary.BigEndian.Uint64(b[0:8]),
+		Seq: binary.BigEndian.Uint64(b[8:16]),
+	}
+}
+
+// Iterates over the decoded elements of a stream listpack
+type streamListpackIterator struct {
+	elements []interface{}
+	pos      int
+}
+
+func (it *streamListpackIterator) next() (interface{}, error) {
+	if it.pos >= len(it.elements) {
+		return nil, ErrInvalidStreamListpack
+	}
+
+	e := it.elements[it.pos]
+	it.pos++
+
+	return e, nil
+}
+
+// Returns the number of elements left
+func (it *streamListpackIterator) remaining() int64 {
+	return int64(len(it.elements) - it.pos)
+}
+
+func (it *streamListpackIterator) nextInt() (int64, error) {
+	e, err := it.next()
+	if err != nil {
+		return 0, err
+	}
+
+	i, ok := e.(int64)
+	if !ok {
+		return 0, ErrInvalidStreamListpack
+	}
+
+	return i, nil
+}
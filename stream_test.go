@@ -0,0 +1,325 @@
+package rdbtools
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestStreamIDString(t *testing.T) {
+	equals(t, "1700000000000-1", StreamID{Ms: 1700000000000, Seq: 1}.String())
+}
+
+func TestStreamMetadataString(t *testing.T) {
+	md := StreamMetadata{Key: KeyObject{Key: "foobar"}, Len: 10, LastID: StreamID{Ms: 1, Seq: 2}}
+	equals(t, "StreamMetadata{Key: foobar, Len: 10, LastID: 1-2}", md.String())
+}
+
+func TestStreamEntryString(t *testing.T) {
+	e := StreamEntry{ID: StreamID{Ms: 1, Seq: 2}, Fields: []StreamField{{Name: "a", Value: "b"}}}
+	equals(t, "StreamEntry{ID: 1-2, Fields: 1}", e.String())
+}
+
+func TestStreamConsumerGroupString(t *testing.T) {
+	g := StreamConsumerGroup{Name: "group", LastID: StreamID{Ms: 1, Seq: 2}}
+	equals(t, "StreamConsumerGroup{Name: group, LastID: 1-2, Pending: 0, Consumers: 0}", g.String())
+}
+
+const testStreamMs = 1700000000000
+
+// Write a stream with two entries (and a deleted one) and a single consumer group
+func writeTestStream(w io.Writer, version int) {
+	writeLen(w, 1) // Number of listpacks
+
+	var nodeKey bytes.Buffer
+	binary.Write(&nodeKey, binary.BigEndian, uint64(testStreamMs))
+	binary.Write(&nodeKey, binary.BigEndian, uint64(0))
+	writeString(w, nodeKey.String())
+
+	lp := newListpack(
+		// Master entry
+		int64(2), int64(1), int64(1), "field", int64(0),
+		// Entry with the master fields
+		int64(streamItemFlagSameFields), int64(0), int64(0), "v1", int64(4),
+		// Deleted entry
+		int64(streamItemFlagSameFields|streamItemFlagDeleted), int64(1), int64(0), "v2", int64(4),
+		// Entry with its own fields
+		int64(0), int64(2), int64(1), int64(2), "a", "1", "b", "2", int64(8),
+	)
+	writeString(w, string(lp))
+
+	writeLen(w, 2) // Length
+
+	// Last ID
+	writeLen(w, testStreamMs+2)
+	writeLen(w, 1)
+
+	if version >= 2 {
+		// First ID
+		writeLen(w, testStreamMs)
+		writeLen(w, 0)
+
+		// Max deleted ID
+		writeLen(w, testStreamMs+1)
+		writeLen(w, 0)
+
+		writeLen(w, 3) // Entries added
+	}
+
+	writeLen(w, 1) // Number of consumer groups
+	writeString(w, "group")
+
+	// Last ID
+	writeLen(w, testStreamMs)
+	writeLen(w, 0)
+
+	if version >= 2 {
+		writeLen(w, 1) // Entries read
+	}
+
+	writeLen(w, 1) // Pending entries
+	w.Write(nodeKey.Bytes())
+	binary.Write(w, binary.LittleEndian, int64(testStreamMs+10)) // Delivery time
+	writeLen(w, 2)                                               // Delivery count
+
+	writeLen(w, 1) // Consumers
+	writeString(w, "consumer")
+	binary.Write(w, binary.LittleEndian, int64(testStreamMs+20)) // Seen time
+	if version >= 3 {
+		binary.Write(w, binary.LittleEndian, int64(testStreamMs+30)) // Active time
+	}
+	writeLen(w, 1) // Pending entries
+	w.Write(nodeKey.Bytes())
+}
+
+func readTestStream(t *testing.T, version int) (StreamMetadata, []StreamEntry, []StreamConsumerGroup) {
+	var buffer bytes.Buffer
+
+	br := bufio.NewWriter(&buffer)
+	writeTestStream(br, version)
+	br.Flush()
+
+	ctx := ParserContext{
+		StreamMetadataCh:       make(chan StreamMetadata),
+		StreamEntriesCh:        make(chan StreamEntry),
+		StreamConsumerGroupsCh: make(chan StreamConsumerGroup),
+	}
+	p := &parser{h: newChannelHandler(ctx)}
+
+	go readAndNotify(t, &buffer, "stream", func(key KeyObject, r io.Reader) error {
+		return p.readStream(key, r, version)
+	})
+
+	var md StreamMetadata
+	var entries []StreamEntry
+	var groups []StreamConsumerGroup
+	stop := false
+	for !stop {
+		select {
+		case v := <-ctx.StreamMetadataCh:
+			md = v
+		case v := <-ctx.StreamEntriesCh:
+			entries = append(entries, v)
+		case v := <-ctx.StreamConsumerGroupsCh:
+			groups = append(groups, v)
+		case <-end:
+			stop = true
+		}
+	}
+
+	return md, entries, groups
+}
+
+func TestReadStream(t *testing.T) {
+	md, entries, groups := readTestStream(t, 3)
+
+	equals(t, "stream", DataToString(md.Key))
+	equals(t, int64(2), md.Len)
+	equals(t, StreamID{Ms: testStreamMs + 2, Seq: 1}, md.LastID)
+	equals(t, StreamID{Ms: testStreamMs, Seq: 0}, md.FirstID)
+	equals(t, StreamID{Ms: testStreamMs + 1, Seq: 0}, md.MaxDeletedID)
+	equals(t, int64(3), md.EntriesAdded)
+
+	equals(t, 2, len(entries))
+	equals(t, StreamID{Ms: testStreamMs, Seq: 0}, entries[0].ID)
+	equals(t, 1, len(entries[0].Fields))
+	equals(t, "field", DataToString(entries[0].Fields[0].Name))
+	equals(t, "v1", DataToString(entries[0].Fields[0].Value))
+	equals(t, StreamID{Ms: testStreamMs + 2, Seq: 1}, entries[1].ID)
+	equals(t, 2, len(entries[1].Fields))
+	equals(t, "a", DataToString(entries[1].Fields[0].Name))
+	equals(t, "1", DataToString(entries[1].Fields[0].Value))
+	equals(t, "b", DataToString(entries[1].Fields[1].Name))
+	equals(t, "2", DataToString(entries[1].Fields[1].Value))
+
+	equals(t, 1, len(groups))
+	g := groups[0]
+	equals(t, "group", DataToString(g.Name))
+	equals(t, StreamID{Ms: testStreamMs, Seq: 0}, g.LastID)
+	equals(t, int64(1), g.EntriesRead)
+	equals(t, []StreamPendingEntry{
+		{ID: StreamID{Ms: testStreamMs}, DeliveryTime: millisecondsToTime(testStreamMs + 10), DeliveryCount: 2},
+	}, g.Pending)
+	equals(t, 1, len(g.Consumers))
+	equals(t, "consumer", DataToString(g.Consumers[0].Name))
+	equals(t, millisecondsToTime(testStreamMs+20), g.Consumers[0].SeenTime)
+	equals(t, millisecondsToTime(testStreamMs+30), g.Consumers[0].ActiveTime)
+	equals(t, []StreamID{{Ms: testStreamMs}}, g.Consumers[0].Pending)
+}
+
+func TestReadStreamVersion1(t *testing.T) {
+	md, entries, groups := readTestStream(t, 1)
+
+	equals(t, int64(2), md.Len)
+	equals(t, StreamID{Ms: testStreamMs + 2, Seq: 1}, md.LastID)
+	equals(t, StreamID{}, md.FirstID)
+	equals(t, int64(-1), md.EntriesAdded)
+	equals(t, 2, len(entries))
+	equals(t, 1, len(groups))
+	equals(t, int64(-1), groups[0].EntriesRead)
+	equals(t, time.Time{}, groups[0].Consumers[0].ActiveTime)
+}
+
+func TestReadStreamNoData(t *testing.T) {
+	var buffer bytes.Buffer
+
+	p := &parser{}
+	err := p.readStream(KeyObject{Key: []byte("stream")}, bufio.NewReader(&buffer), 3)
+	equals(t, io.EOF, err)
+}
+
+func TestReadStreamInvalidNodeKey(t *testing.T) {
+	var buffer bytes.Buffer
+
+	br := bufio.NewWriter(&buffer)
+	writeLen(br, 1)
+	writeString(br, "foobar")
+	br.Flush()
+
+	p := &parser{}
+	err := p.readStream(KeyObject{Key: []byte("stream")}, bufio.NewReader(&buffer), 3)
+	equals(t, ErrInvalidStreamID, err)
+}
+
+func TestReadStreamInvalidListpack(t *testing.T) {
+	var buffer bytes.Buffer
+
+	br := bufio.NewWriter(&buffer)
+	writeLen(br, 1)
+	br.WriteByte(16)
+	br.Write(make([]byte, 16))
+	writeString(br, string(newListpack(int64(1), int64(0), int64(1), "field", int64(0))))
+	writeLen(br, 1)
+	writeLen(br, 0)
+	writeLen(br, 0)
+	br.Flush()
+
+	p := &parser{h: NopHandler{}}
+	err := p.readStream(KeyObject{Key: []byte("stream")}, bufio.NewReader(&buffer), 1)
+	equals(t, ErrInvalidStreamListpack, err)
+}
+
+func TestReadStreamBogusFieldsCount(t *testing.T) {
+	testCases := [][]byte{
+		newListpack(int64(1), int64(0), int64(math.MaxInt64), "field", int64(0)),
+		newListpack(int64(1), int64(0), int64(0), int64(0), int64(0), int64(0), int64(0), int64(math.MaxInt64), "a", "1", int64(8)),
+	}
+
+	for _, lp := range testCases {
+		var buffer bytes.Buffer
+
+		br := bufio.NewWriter(&buffer)
+		writeLen(br, 1)
+		br.WriteByte(16)
+		br.Write(make([]byte, 16))
+		writeString(br, string(lp))
+		writeLen(br, 1)
+		writeLen(br, 0)
+		writeLen(br, 0)
+		br.Flush()
+
+		p := &parser{h: NopHandler{}}
+		err := p.readStream(KeyObject{Key: []byte("stream")}, bufio.NewReader(&buffer), 1)
+		equals(t, ErrInvalidStreamListpack, err)
+	}
+}
+
+func TestReadStreamNoConsumerGroups(t *testing.T) {
+	var buffer bytes.Buffer
+
+	br := bufio.NewWriter(&buffer)
+	writeLen(br, 0)
+	writeLen(br, 0)
+	writeLen(br, 0)
+	writeLen(br, 0)
+	br.Flush()
+
+	p := &parser{h: NopHandler{}}
+	err := p.readStream(KeyObject{Key: []byte("stream")}, bufio.NewReader(&buffer), 1)
+	equals(t, io.EOF, err)
+}
+
+func TestReadStreamConsumerGroupNoConsumers(t *testing.T) {
+	var buffer bytes.Buffer
+
+	br := bufio.NewWriter(&buffer)
+	writeString(br, "group")
+	writeLen(br, 0)
+	writeLen(br, 0)
+	writeLen(br, 0)
+	br.Flush()
+
+	p := &parser{}
+	_, err := p.readStreamConsumerGroup(bufio.NewReader(&buffer), 1)
+	equals(t, io.EOF, err)
+}
+
+func TestReadStreamConsumerGroupBogusLengths(t *testing.T) {
+	var buffer bytes.Buffer
+
+	// Pending entries
+	br := bufio.NewWriter(&buffer)
+	writeString(br, "group")
+	writeLen(br, 0)
+	writeLen(br, 0)
+	br.WriteByte(0x81)
+	binary.Write(br, binary.BigEndian, uint64(math.MaxUint64))
+	br.Flush()
+
+	p := &parser{}
+	_, err := p.readStreamConsumerGroup(bufio.NewReader(&buffer), 1)
+	equals(t, io.EOF, err)
+
+	// Consumers
+	buffer.Reset()
+	writeString(br, "group")
+	writeLen(br, 0)
+	writeLen(br, 0)
+	writeLen(br, 0)
+	br.WriteByte(0x81)
+	binary.Write(br, binary.BigEndian, uint64(math.MaxUint64))
+	br.Flush()
+
+	_, err = p.readStreamConsumerGroup(bufio.NewReader(&buffer), 1)
+	equals(t, io.EOF, err)
+
+	// Pending entries of a consumer
+	buffer.Reset()
+	writeString(br, "group")
+	writeLen(br, 0)
+	writeLen(br, 0)
+	writeLen(br, 0)
+	writeLen(br, 1)
+	writeString(br, "consumer")
+	binary.Write(br, binary.LittleEndian, uint64(testStreamMs))
+	br.WriteByte(0x81)
+	binary.Write(br, binary.BigEndian, uint64(math.MaxUint64))
+	br.Flush()
+
+	_, err = p.readStreamConsumerGroup(bufio.NewReader(&buffer), 1)
+	equals(t, io.EOF, err)
+}
@@ -11,7 +11,7 @@ type zipListOnElementCallback func(element interface{}) error
 func (p *parser) readZipList(r io.Reader, onLenCallback zipListOnLenCallback, onElementCallback zipListOnElementCallback) error {
 	var zlBytes int32
 	var zlTail int32
-	var zlLen int16
+	var zlLen uint16
 	var err error
 
 	if err = binary.Read(r, binary.LittleEndian, &zlBytes); err != nil {
@@ -26,11 +26,18 @@ func (p *parser) readZipList(r io.Reader, onLenCallback zipListOnLenCallback, on
 		return err
 	}
 
-	if err := onLenCallback(int64(zlLen)); err != nil {
-		return err
+	// Same as a listpack, a length of 65535 means the zip list has too many elements for the header,
+	// so we have to read all of them until the end byte before knowing it.
+	var elements []interface{}
+	if zlLen == 65535 {
+		elements = make([]interface{}, 0)
+	} else {
+		if err := onLenCallback(int64(zlLen)); err != nil {
+			return err
+		}
 	}
 
-	for i := 0; i < int(zlLen); i++ {
+	for i := 0; zlLen == 65535 || i < int(zlLen); i++ {
 		_, err := io.ReadFull(r, p.scratch[0:1])
 		if err != nil {
 			return err
@@ -40,7 +47,9 @@ func (p *parser) readZipList(r io.Reader, onLenCallback zipListOnLenCallback, on
 
 		// Read length of the previous entry
 		// We don't use it though
-		if b <= 0xFD { // 253
+		if b == 0xFF && zlLen == 65535 {
+			break
+		} else if b <= 0xFD { // 253
 			// Do nothing
 		} else if b == 0xFE { // 254
 			var tmp int32
@@ -63,7 +72,7 @@ func (p *parser) readZipList(r io.Reader, onLenCallback zipListOnLenCallback, on
 		if (flag & 0xC0) == 0 {
 			// String with length <= 63 bytes
 			length := int64(flag & 0x3F)
-			data, err = readBytes(r, length)
+			data, err = readBytes(r, uint64(length))
 			if err != nil {
 				return err
 			}
@@ -75,7 +84,7 @@ func (p *parser) readZipList(r io.Reader, onLenCallback zipListOnLenCallback, on
 			}
 
 			length := (int64(flag&0x3F) << 8) | int64(p.scratch[0])
-			data, err = readBytes(r, length)
+			data, err = readBytes(r, uint64(length))
 			if err != nil {
 				return err
 			}
@@ -87,7 +96,7 @@ func (p *parser) readZipList(r io.Reader, onLenCallback zipListOnLenCallback, on
 			}
 
 			length := int64(tmp)
-			data, err = readBytes(r, length)
+			data, err = readBytes(r, uint64(length))
 			if err != nil {
 				return err
 			}
@@ -137,9 +146,24 @@ func (p *parser) readZipList(r io.Reader, onLenCallback zipListOnLenCallback, on
 			data = (int(int(flag) & 0x0F)) - 1
 		}
 
-		if err := onElementCallback(data); err != nil {
+		if zlLen == 65535 {
+			elements = append(elements, data)
+		} else {
+			if err := onElementCallback(data); err != nil {
+				return err
+			}
+		}
+	}
+
+	if zlLen == 65535 {
+		if err := onLenCallback(int64(len(elements))); err != nil {
 			return err
 		}
+		for _, e := range elements {
+			if err := onElementCallback(e); err != nil {
+				return err
+			}
+		}
 	}
 
 	return nil
@@ -689,3 +689,39 @@ func TestReadZipListOnElementCallbackError(t *testing.T) {
 	err := p.readZipList(bufio.NewReader(&buffer), onLenCallback, onElementCallback)
 	equals(t, myErr, err)
 }
+
+func TestReadZipListUnknownLength(t *testing.T) {
+	var buffer bytes.Buffer
+
+	br := bufio.NewWriter(&buffer)
+	p := &parser{}
+
+	br.Write([]byte{0, 0, 0, 0}) // zlBytes
+	br.Write([]byte{0, 0, 0, 0}) // zlTail
+	br.Write([]byte{0xFF, 0xFF}) // zlLen
+	br.WriteByte(0)              // len prev entry
+	br.WriteByte(3)              // Special flag
+	br.WriteString("foo")
+	br.WriteByte(5)    // len prev entry
+	br.WriteByte(0xFE) // int8
+	br.WriteByte(42)
+	br.WriteByte(0xFF) // End
+	br.Flush()
+
+	var length int64
+	elements := make([]string, 0)
+	onLenCallback := func(l int64) error {
+		length = l
+		equals(t, 0, len(elements))
+		return nil
+	}
+	onElementCallback := func(e interface{}) error {
+		elements = append(elements, DataToString(e))
+		return nil
+	}
+
+	err := p.readZipList(bufio.NewReader(&buffer), onLenCallback, onElementCallback)
+	ok(t, err)
+	equals(t, int64(2), length)
+	equals(t, []string{"foo", "42"}, elements)
+}